@@ -1,148 +1,308 @@
-package bloomFilter
-
-import (
-	"context"
-	"crypto/md5"
-	"encoding/binary"
-	"fmt"
-	"github.com/redis/go-redis/v9"
-	"github.com/spaolacci/murmur3"
-	"golang.org/x/crypto/blake2b"
-	"strconv"
-	"time"
-)
-
-type BloomFilter struct {
-	cmd       redis.Cmdable
-	bitmapKey string
-}
-
-func NewBloomFilter(cmd redis.Cmdable, bitmapKey string) *BloomFilter {
-	return &BloomFilter{
-		cmd:       cmd,
-		bitmapKey: bitmapKey,
-	}
-}
-
-func (b *BloomFilter) GetMurmur3(key string) (uint64, error) {
-	// 将手机号转为uint64类型
-	p, err := strconv.ParseUint(key, 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("手机号转换失败，%w", err)
-	}
-	// 创建Murmur3的实例对象
-	obj := murmur3.New64()
-	// 将手机号写入hash对象
-	binary.Write(obj, binary.BigEndian, p)
-	// 计算Hash值
-	hash := obj.Sum64()
-	return hash, nil
-}
-
-func (b *BloomFilter) GetMD5(key string) uint64 {
-	// 创建MD5的实例对象
-	obj := md5.New()
-	// 获取md5哈希值
-	bytes := obj.Sum([]byte(key))
-	// 将MD5值转为uint64
-	res := binary.BigEndian.Uint64(bytes)
-	return res
-}
-
-func (b *BloomFilter) GetBLAKE2(key string) (uint64, error) {
-	// 创建blake2b的实例对象
-	new256, err := blake2b.New256([]byte(key))
-	// 计算哈希值
-	bytes := new256.Sum(nil)
-	// 将哈希值转为uint64
-	res := binary.BigEndian.Uint64(bytes)
-	return res, err
-}
-
-func (b *BloomFilter) SetMurmur3BitMap(key string) error {
-	murmur3, err := b.GetMurmur3(key)
-	if err != nil {
-		return err
-	}
-	murmur3 = murmur3 % (1 << 32) // 取模 2^32
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	// 创建redis的bitmap
-	err = b.cmd.SetBit(ctx, b.bitmapKey, int64(murmur3), 1).Err()
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (b *BloomFilter) SetMD5BitMap(key string) error {
-	md5 := b.GetMD5(key) % (1 << 32)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	// 创建redis的bitmap
-	err := b.cmd.SetBit(ctx, b.bitmapKey, int64(md5), 1).Err()
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (b *BloomFilter) SetBLAKE2BitMap(key string) error {
-	blake2, err := b.GetBLAKE2(key)
-	if err != nil {
-		return err
-	}
-	blake2 = blake2 % (1 << 32)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	// 创建redis的bitmap
-	err = b.cmd.SetBit(ctx, b.bitmapKey, int64(blake2), 1).Err()
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (b *BloomFilter) GetMurmur3BitMap(key string) (int64, error) {
-	murmur3, err := b.GetMurmur3(key)
-	if err != nil {
-		return 0, err
-	}
-	murmur3 = murmur3 % (1 << 32) // 取模 2^32
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	// 获取redis的bitmap
-	res, err := b.cmd.GetBit(ctx, b.bitmapKey, int64(murmur3)).Result()
-	if err != nil {
-		return res, err
-	}
-	return res, nil
-}
-
-func (b *BloomFilter) GetMD5BitMap(key string) (int64, error) {
-	md5 := b.GetMD5(key) % (1 << 32)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	// 获取redis的bitmap
-	res, err := b.cmd.GetBit(ctx, b.bitmapKey, int64(md5)).Result()
-	if err != nil {
-		return res, err
-	}
-	return res, nil
-}
-
-func (b *BloomFilter) GetBLAKE2BitMap(key string) (int64, error) {
-	blake2, err := b.GetBLAKE2(key)
-	if err != nil {
-		return 0, err
-	}
-	blake2 = blake2 % (1 << 32)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	// 获取redis的bitmap
-	res, err := b.cmd.GetBit(ctx, b.bitmapKey, int64(blake2)).Result()
-	if err != nil {
-		return res, err
-	}
-	return res, nil
-}
+package bloomFilter
+
+import (
+	"context"
+	"crypto/md5"
+	_ "embed"
+	"encoding/binary"
+	"github.com/redis/go-redis/v9"
+	"github.com/spaolacci/murmur3"
+	"golang.org/x/crypto/blake2b"
+	"strconv"
+	"time"
+)
+
+//go:embed lua/add.lua
+var luaAdd string
+
+var addScript = redis.NewScript(luaAdd)
+
+type BloomFilter struct {
+	cmd       redis.Cmdable
+	bitmapKey string
+	m         uint64 // bitmap的大小（bit数）
+	k         uint64 // 哈希函数的个数
+}
+
+// NewBloomFilter 创建布隆过滤器，bitmap的大小是2^32，使用3个哈希函数
+func NewBloomFilter(cmd redis.Cmdable, bitmapKey string) *BloomFilter {
+	return &BloomFilter{
+		cmd:       cmd,
+		bitmapKey: bitmapKey,
+		m:         maxBits,
+		k:         3,
+	}
+}
+
+// NewBloomFilterWithEstimates 根据预计的元素个数n和期望的误判率p，计算bitmap的大小m和哈希函数的个数k
+//
+//	比如n=1000万，p=0.001，m约为1.44亿bit（约17MB），k=10
+//	redis的bitmap最大是2^32bit，m超过2^32时使用2^32，此时实际的误判率会高于p
+//	n为0或者p不在(0, 1)之间时panic
+func NewBloomFilterWithEstimates(cmd redis.Cmdable, bitmapKey string, n uint64, p float64) *BloomFilter {
+	m, k := EstimateParameters(n, p)
+	return &BloomFilter{
+		cmd:       cmd,
+		bitmapKey: bitmapKey,
+		m:         m,
+		k:         k,
+	}
+}
+
+// Add 添加元素，使用lua脚本一次设置k个bit，重建期间同时写入影子key
+func (b *BloomFilter) Add(ctx context.Context, key string) error {
+	return b.AddBytes(ctx, []byte(key))
+}
+
+// AddBytes 添加任意字节序列的元素
+func (b *BloomFilter) AddBytes(ctx context.Context, data []byte) error {
+	return b.addMany(ctx, [][]byte{data})
+}
+
+// AddMany 批量添加元素，使用lua脚本在一次网络往返中设置N个元素的k个bit
+//
+//	用于从数据库预热布隆过滤器，每批的数量建议在1000左右，避免单个lua脚本执行时间过长
+func (b *BloomFilter) AddMany(ctx context.Context, keys []string) error {
+	return b.addMany(ctx, toBytes(keys))
+}
+
+// MightContain 判断元素是否可能存在，返回false表示一定不存在，返回true表示可能存在（有误判率）
+func (b *BloomFilter) MightContain(ctx context.Context, key string) (bool, error) {
+	return b.MightContainBytes(ctx, []byte(key))
+}
+
+// MightContainBytes 判断任意字节序列的元素是否可能存在
+func (b *BloomFilter) MightContainBytes(ctx context.Context, data []byte) (bool, error) {
+	res, err := b.mightContainMany(ctx, [][]byte{data})
+	if err != nil {
+		return false, err
+	}
+	return res[0], nil
+}
+
+// MightContainMany 批量判断元素是否可能存在，使用pipeline在一次网络往返中读取N个元素的k个bit，返回值和keys一一对应
+func (b *BloomFilter) MightContainMany(ctx context.Context, keys []string) ([]bool, error) {
+	return b.mightContainMany(ctx, toBytes(keys))
+}
+
+func (b *BloomFilter) addMany(ctx context.Context, data [][]byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	// ARGV数组：过滤器的参数，之后是要设置的bit的位置
+	args := make([]any, 0, 1+len(data)*int(b.k))
+	args = append(args, b.params())
+	for _, d := range data {
+		for _, offset := range locations(d, b.k, b.m) {
+			args = append(args, offset)
+		}
+	}
+	return addScript.Run(ctx, b.cmd, []string{b.bitmapKey, b.shadowKey(), b.lockKey()}, args...).Err()
+}
+
+// setBits 使用pipeline设置key中N个元素的k个bit
+func (b *BloomFilter) setBits(ctx context.Context, key string, data [][]byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	pipe := b.cmd.Pipeline()
+	for _, d := range data {
+		for _, offset := range locations(d, b.k, b.m) {
+			pipe.SetBit(ctx, key, int64(offset), 1)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *BloomFilter) mightContainMany(ctx context.Context, data [][]byte) ([]bool, error) {
+	res := make([]bool, len(data))
+	if len(data) == 0 {
+		return res, nil
+	}
+	pipe := b.cmd.Pipeline()
+	cmds := make([][]*redis.IntCmd, len(data))
+	for i, d := range data {
+		for _, offset := range locations(d, b.k, b.m) {
+			cmds[i] = append(cmds[i], pipe.GetBit(ctx, b.bitmapKey, int64(offset)))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i := range cmds {
+		res[i] = true
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				// 有一个bit为0，元素一定不存在
+				res[i] = false
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// GetMurmur3 计算key的Murmur3哈希值
+//
+//	数字（比如手机号）转为uint64后计算哈希值，和之前写入bitmap的位置保持一致；
+//	其他的key（比如邮箱、用户名、订单号）直接使用原始的字节计算哈希值
+//	注意：数字的前导0会被忽略，"0123"和"123"的哈希值相同
+//
+// Deprecated: 使用 Add 和 MightContain 代替，它们直接使用key的原始字节计算k个位置
+func (b *BloomFilter) GetMurmur3(key string) (uint64, error) {
+	// 创建Murmur3的实例对象
+	obj := murmur3.New64()
+	// 将手机号转为uint64类型
+	p, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		// 不是数字，写入原始的字节
+		obj.Write([]byte(key))
+	} else {
+		// 将手机号写入hash对象
+		binary.Write(obj, binary.BigEndian, p)
+	}
+	// 计算Hash值
+	hash := obj.Sum64()
+	return hash, nil
+}
+
+// GetMD5 计算key的"MD5哈希值"
+//
+//	注意：obj.Sum([]byte(key))会把空输入的摘要追加到key的后面，返回值实际上是key的前8个字节，
+//	前8个字节相同的key（比如同一个前缀的订单号）会冲突；为了和已经写入bitmap的位置保持一致，不再修改
+//
+// Deprecated: 使用 Add 和 MightContain 代替
+func (b *BloomFilter) GetMD5(key string) uint64 {
+	// 创建MD5的实例对象
+	obj := md5.New()
+	// 获取md5哈希值
+	bytes := obj.Sum([]byte(key))
+	// 将MD5值转为uint64
+	res := binary.BigEndian.Uint64(bytes)
+	return res
+}
+
+// GetBLAKE2 计算key的BLAKE2哈希值
+//
+//	key被用作blake2b的MAC密钥，超过64个字节时返回error
+//
+// Deprecated: 使用 Add 和 MightContain 代替，它们支持任意长度的key
+func (b *BloomFilter) GetBLAKE2(key string) (uint64, error) {
+	// 创建blake2b的实例对象
+	new256, err := blake2b.New256([]byte(key))
+	if err != nil {
+		return 0, err
+	}
+	// 计算哈希值
+	bytes := new256.Sum(nil)
+	// 将哈希值转为uint64
+	res := binary.BigEndian.Uint64(bytes)
+	return res, nil
+}
+
+// SetMurmur3BitMap 使用单个哈希函数写入bitmap
+//
+// Deprecated: 只使用一个哈希函数，误判率很高，使用 Add 和 MightContain 代替
+func (b *BloomFilter) SetMurmur3BitMap(key string) error {
+	murmur3, err := b.GetMurmur3(key)
+	if err != nil {
+		return err
+	}
+	murmur3 = murmur3 % (1 << 32) // 取模 2^32
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 创建redis的bitmap
+	err = b.cmd.SetBit(ctx, b.bitmapKey, int64(murmur3), 1).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetMD5BitMap 使用单个哈希函数写入bitmap
+//
+// Deprecated: 只使用一个哈希函数，误判率很高，使用 Add 和 MightContain 代替
+func (b *BloomFilter) SetMD5BitMap(key string) error {
+	md5 := b.GetMD5(key) % (1 << 32)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 创建redis的bitmap
+	err := b.cmd.SetBit(ctx, b.bitmapKey, int64(md5), 1).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetBLAKE2BitMap 使用单个哈希函数写入bitmap
+//
+// Deprecated: 只使用一个哈希函数，误判率很高，使用 Add 和 MightContain 代替
+func (b *BloomFilter) SetBLAKE2BitMap(key string) error {
+	blake2, err := b.GetBLAKE2(key)
+	if err != nil {
+		return err
+	}
+	blake2 = blake2 % (1 << 32)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 创建redis的bitmap
+	err = b.cmd.SetBit(ctx, b.bitmapKey, int64(blake2), 1).Err()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMurmur3BitMap 使用单个哈希函数读取bitmap
+//
+// Deprecated: 只使用一个哈希函数，误判率很高，使用 Add 和 MightContain 代替
+func (b *BloomFilter) GetMurmur3BitMap(key string) (int64, error) {
+	murmur3, err := b.GetMurmur3(key)
+	if err != nil {
+		return 0, err
+	}
+	murmur3 = murmur3 % (1 << 32) // 取模 2^32
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 获取redis的bitmap
+	res, err := b.cmd.GetBit(ctx, b.bitmapKey, int64(murmur3)).Result()
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// GetMD5BitMap 使用单个哈希函数读取bitmap
+//
+// Deprecated: 只使用一个哈希函数，误判率很高，使用 Add 和 MightContain 代替
+func (b *BloomFilter) GetMD5BitMap(key string) (int64, error) {
+	md5 := b.GetMD5(key) % (1 << 32)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 获取redis的bitmap
+	res, err := b.cmd.GetBit(ctx, b.bitmapKey, int64(md5)).Result()
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// GetBLAKE2BitMap 使用单个哈希函数读取bitmap
+//
+// Deprecated: 只使用一个哈希函数，误判率很高，使用 Add 和 MightContain 代替
+func (b *BloomFilter) GetBLAKE2BitMap(key string) (int64, error) {
+	blake2, err := b.GetBLAKE2(key)
+	if err != nil {
+		return 0, err
+	}
+	blake2 = blake2 % (1 << 32)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 获取redis的bitmap
+	res, err := b.cmd.GetBit(ctx, b.bitmapKey, int64(blake2)).Result()
+	if err != nil {
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+package bloomFilter
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRedisClient 连接本地的redis，redis不可用时跳过测试
+func newRedisClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis不可用，跳过测试，err=%v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+// newTestKey 生成测试使用的key，测试结束后删除
+func newTestKey(t *testing.T, client redis.Cmdable) string {
+	key := "bloom:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(context.Background(), key)
+	})
+	return key
+}
+
+// TestBloomFilter_ArbitraryKeys 任意字符串的key（邮箱、订单号、超长的key、带前导0的数字）
+func TestBloomFilter_ArbitraryKeys(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	f := NewBloomFilterWithEstimates(client, newTestKey(t, client), 1000, 0.001)
+	added := []string{
+		"user@example.com",
+		"order:20241001000001",
+		strings.Repeat("long-key:", 100),
+		"布隆过滤器",
+		"0123",
+	}
+	// 和添加过的元素前缀相同、长度不同，或者数字的值相同
+	notAdded := []string{
+		"order:20241001000002",
+		strings.Repeat("long-key:", 101),
+		"123",
+		"",
+	}
+	if err := f.AddMany(ctx, added); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range added {
+		if ok, err := f.MightContain(ctx, key); err != nil || !ok {
+			t.Fatalf("添加过的元素应该存在，key=%q，err=%v", key, err)
+		}
+	}
+	res, err := f.MightContainMany(ctx, notAdded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range res {
+		if ok {
+			t.Fatalf("没有添加过的元素不应该存在，key=%q", notAdded[i])
+		}
+	}
+}
+
+// TestBloomFilter_LegacyLongKey 旧的BLAKE2方法，超过64个字节的key返回error，而不是panic
+func TestBloomFilter_LegacyLongKey(t *testing.T) {
+	f := NewBloomFilter(nil, "bloom:test")
+	key := strings.Repeat("a", 65)
+	if _, err := f.GetBLAKE2(key); err == nil {
+		t.Fatal("超过64个字节的key应该返回error")
+	}
+	if err := f.SetBLAKE2BitMap(key); err == nil {
+		t.Fatal("超过64个字节的key应该返回error")
+	}
+	if _, err := f.GetBLAKE2BitMap(key); err == nil {
+		t.Fatal("超过64个字节的key应该返回error")
+	}
+	if _, err := f.GetBLAKE2("user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedBloomFilter(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	emails := NewTypedBloomFilter[string](
+		NewBloomFilterWithEstimates(client, newTestKey(t, client), 1000, 0.001), StringEncoder)
+	if err := emails.AddMany(ctx, []string{"a@example.com", "b@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := emails.MightContainMany(ctx, []string{"a@example.com", "c@example.com", "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res[0] || res[1] || !res[2] {
+		t.Fatalf("want=[true false true]，got=%v", res)
+	}
+
+	// 编码器决定元素的字节序列，int64(-1)和uint64的最大值编码后相同
+	ids := NewTypedBloomFilter[int64](
+		NewBloomFilterWithEstimates(client, newTestKey(t, client), 1000, 0.001), Int64Encoder)
+	if err = ids.Add(ctx, -1); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := ids.MightContain(ctx, -1); !ok {
+		t.Fatal("添加过的元素应该存在")
+	}
+	if ok, _ := ids.MightContain(ctx, 1); ok {
+		t.Fatal("没有添加过的元素不应该存在")
+	}
+	if string(Int64Encoder(-1)) != string(Uint64Encoder(^uint64(0))) {
+		t.Fatal("int64和uint64应该使用相同的大端序编码")
+	}
+}
@@ -0,0 +1,137 @@
+package bloomFilter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+)
+
+//go:embed lua/counting_remove.lua
+var luaCountingRemove string
+
+var countingRemoveScript = redis.NewScript(luaCountingRemove)
+
+// maxCounters 4位计数器的最大个数，redis的bitmap最大是2^32bit，可以保存2^30个计数器
+const maxCounters = 1 << 30
+
+// maxCount 4位计数器的最大值，计数器饱和后不再增加，也不再减少
+const maxCount = 15
+
+// CountingFilter 计数布隆过滤器，支持删除元素
+type CountingFilter interface {
+	Filter
+	// Remove 删除元素，返回false表示元素一定不存在，没有删除
+	// 只能删除添加过的元素，删除没有添加过的元素会导致其他元素被误判为不存在
+	Remove(ctx context.Context, key string) (bool, error)
+}
+
+var (
+	_ CountingFilter = (*RedisCountingFilter)(nil)
+	_ CountingFilter = (*MemoryCountingFilter)(nil)
+)
+
+// RedisCountingFilter redis的计数布隆过滤器，使用BITFIELD保存m个4位计数器
+//
+//	添加元素时k个计数器加1，删除元素时k个计数器减1，计数器都大于0时元素可能存在
+//	计数器使用OVERFLOW SAT，最大值是15，饱和后不再变化（删除时也不减少），避免溢出后回绕成0导致误删
+//	内存占用是普通布隆过滤器的4倍
+type RedisCountingFilter struct {
+	cmd redis.Cmdable
+	key string
+	m   uint64 // 计数器的个数
+	k   uint64 // 哈希函数的个数
+}
+
+// NewRedisCountingFilter 根据预计的元素个数n和期望的误判率p创建计数布隆过滤器，计数器最多2^30个
+//
+//	n为0或者p不在(0, 1)之间时panic
+func NewRedisCountingFilter(cmd redis.Cmdable, key string, n uint64, p float64) *RedisCountingFilter {
+	m, k := EstimateParameters(n, p)
+	return &RedisCountingFilter{
+		cmd: cmd,
+		key: key,
+		m:   min(m, maxCounters),
+		k:   k,
+	}
+}
+
+// Add 添加元素，k个计数器加1
+func (r *RedisCountingFilter) Add(ctx context.Context, key string) error {
+	return r.AddMany(ctx, []string{key})
+}
+
+// AddMany 批量添加元素，使用pipeline在一次网络往返中完成
+func (r *RedisCountingFilter) AddMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := r.cmd.Pipeline()
+	for _, key := range keys {
+		args := []any{"OVERFLOW", "SAT"}
+		for _, offset := range locations([]byte(key), r.k, r.m) {
+			args = append(args, "INCRBY", "u4", counterOffset(offset), 1)
+		}
+		pipe.BitField(ctx, r.key, args...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Remove 删除元素，k个计数器减1，有一个计数器为0时元素一定不存在，不做任何修改
+func (r *RedisCountingFilter) Remove(ctx context.Context, key string) (bool, error) {
+	offsets := locations([]byte(key), r.k, r.m)
+	args := make([]any, len(offsets))
+	for i, offset := range offsets {
+		args[i] = offset
+	}
+	res, err := countingRemoveScript.Run(ctx, r.cmd, []string{r.key}, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// MightContain 判断元素是否可能存在，k个计数器都大于0时可能存在
+func (r *RedisCountingFilter) MightContain(ctx context.Context, key string) (bool, error) {
+	res, err := r.MightContainMany(ctx, []string{key})
+	if err != nil {
+		return false, err
+	}
+	return res[0], nil
+}
+
+// MightContainMany 批量判断元素是否可能存在，返回值和keys一一对应
+func (r *RedisCountingFilter) MightContainMany(ctx context.Context, keys []string) ([]bool, error) {
+	res := make([]bool, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+	pipe := r.cmd.Pipeline()
+	cmds := make([]*redis.IntSliceCmd, len(keys))
+	for i, key := range keys {
+		var args []any
+		for _, offset := range locations([]byte(key), r.k, r.m) {
+			args = append(args, "GET", "u4", counterOffset(offset))
+		}
+		cmds[i] = pipe.BitField(ctx, r.key, args...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		res[i] = true
+		for _, count := range cmd.Val() {
+			if count == 0 {
+				res[i] = false
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// counterOffset 第i个计数器在BITFIELD中的偏移量，#i表示第i个u4，即第i*4个bit
+func counterOffset(i uint64) string {
+	return "#" + strconv.FormatUint(i, 10)
+}
@@ -0,0 +1,106 @@
+package bloomFilter
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryCountingFilter 本地内存的计数布隆过滤器，每个字节保存两个4位计数器
+//
+//	删除时需要先检查k个计数器，再同时减1，使用读写锁保证检查和修改是原子的
+type MemoryCountingFilter struct {
+	mu       sync.RWMutex
+	counters []byte
+	m        uint64 // 计数器的个数
+	k        uint64 // 哈希函数的个数
+}
+
+// NewMemoryCountingFilter 根据预计的元素个数n和期望的误判率p创建本地内存的计数布隆过滤器
+//
+//	n为0或者p不在(0, 1)之间时panic
+func NewMemoryCountingFilter(n uint64, p float64) *MemoryCountingFilter {
+	m, k := EstimateParameters(n, p)
+	return &MemoryCountingFilter{
+		counters: make([]byte, (m+1)/2),
+		m:        m,
+		k:        k,
+	}
+}
+
+// Add 添加元素，k个计数器加1，计数器最大是15
+func (f *MemoryCountingFilter) Add(ctx context.Context, key string) error {
+	offsets := locations([]byte(key), f.k, f.m)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, offset := range offsets {
+		if count := f.get(offset); count < maxCount {
+			f.set(offset, count+1)
+		}
+	}
+	return nil
+}
+
+// AddMany 批量添加元素
+func (f *MemoryCountingFilter) AddMany(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		_ = f.Add(ctx, key)
+	}
+	return nil
+}
+
+// Remove 删除元素，k个计数器减1，有一个计数器为0时元素一定不存在，不做任何修改；饱和的计数器不减少
+func (f *MemoryCountingFilter) Remove(ctx context.Context, key string) (bool, error) {
+	offsets := locations([]byte(key), f.k, f.m)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, offset := range offsets {
+		if f.get(offset) == 0 {
+			return false, nil
+		}
+	}
+	for _, offset := range offsets {
+		// 同一个元素的k个位置可能重复，重复的位置会被减多次，和添加时加多次对应
+		if count := f.get(offset); count > 0 && count < maxCount {
+			f.set(offset, count-1)
+		}
+	}
+	return true, nil
+}
+
+// MightContain 判断元素是否可能存在，k个计数器都大于0时可能存在
+func (f *MemoryCountingFilter) MightContain(ctx context.Context, key string) (bool, error) {
+	offsets := locations([]byte(key), f.k, f.m)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, offset := range offsets {
+		if f.get(offset) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MightContainMany 批量判断元素是否可能存在，返回值和keys一一对应
+func (f *MemoryCountingFilter) MightContainMany(ctx context.Context, keys []string) ([]bool, error) {
+	res := make([]bool, len(keys))
+	for i, key := range keys {
+		res[i], _ = f.MightContain(ctx, key)
+	}
+	return res, nil
+}
+
+// get 第i个计数器的值，偶数下标保存在高4位，奇数下标保存在低4位（和redis的BITFIELD u4 #i一致）
+func (f *MemoryCountingFilter) get(i uint64) byte {
+	if i%2 == 0 {
+		return f.counters[i/2] >> 4
+	}
+	return f.counters[i/2] & 0x0f
+}
+
+func (f *MemoryCountingFilter) set(i uint64, count byte) {
+	if i%2 == 0 {
+		f.counters[i/2] = f.counters[i/2]&0x0f | count<<4
+	} else {
+		f.counters[i/2] = f.counters[i/2]&0xf0 | count
+	}
+}
@@ -0,0 +1,143 @@
+package bloomFilter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryCountingFilter(t *testing.T) {
+	ctx := context.Background()
+	f := NewMemoryCountingFilter(1000, 0.01)
+
+	_ = f.AddMany(ctx, []string{"13800138000", "13800138001"})
+	// 删除没有添加过的元素，不会修改计数器
+	if ok, _ := f.Remove(ctx, "13900139000"); ok {
+		t.Fatal("没有添加过的元素，不应该删除成功")
+	}
+	if ok, _ := f.Remove(ctx, "13800138000"); !ok {
+		t.Fatal("添加过的元素，应该删除成功")
+	}
+	res, _ := f.MightContainMany(ctx, []string{"13800138000", "13800138001"})
+	if res[0] || !res[1] {
+		t.Fatalf("删除后的元素不应该存在，其他元素不受影响，got=%v", res)
+	}
+
+	// 计数器饱和后，删除不会减少计数器，元素仍然存在
+	for i := 0; i < 20; i++ {
+		_ = f.Add(ctx, "13700137000")
+	}
+	for i := 0; i < 20; i++ {
+		_, _ = f.Remove(ctx, "13700137000")
+	}
+	if ok, _ := f.MightContain(ctx, "13700137000"); !ok {
+		t.Fatal("计数器饱和后，元素应该一直存在")
+	}
+}
+
+// counters 读取元素的k个计数器
+func counters(t *testing.T, f *RedisCountingFilter, key string) []int64 {
+	t.Helper()
+	var args []any
+	for _, offset := range locations([]byte(key), f.k, f.m) {
+		args = append(args, "GET", "u4", counterOffset(offset))
+	}
+	res, err := f.cmd.BitField(context.Background(), f.key, args...).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// assertCounters 计数器的值都等于want
+func assertCounters(t *testing.T, f *RedisCountingFilter, key string, want int64) {
+	t.Helper()
+	for i, count := range counters(t, f, key) {
+		if count != want {
+			t.Fatalf("第%d个计数器，want=%d，got=%d", i, want, count)
+		}
+	}
+}
+
+func TestRedisCountingFilter(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	f := NewRedisCountingFilter(client, newTestKey(t, client), 1000, 0.001)
+
+	if err := f.AddMany(ctx, []string{"13800138000", "13800138001", "13800138000"}); err != nil {
+		t.Fatal(err)
+	}
+	assertCounters(t, f, "13800138000", 2)
+	assertCounters(t, f, "13800138001", 1)
+	ok, err := f.Remove(ctx, "13800138000")
+	if err != nil || !ok {
+		t.Fatalf("添加过的元素，应该删除成功，err=%v", err)
+	}
+	assertCounters(t, f, "13800138000", 1)
+	if ok, _ = f.Remove(ctx, "13800138000"); !ok {
+		t.Fatal("添加了两次的元素，可以删除两次")
+	}
+	res, err := f.MightContainMany(ctx, []string{"13800138000", "13800138001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0] || !res[1] {
+		t.Fatalf("删除后的元素不应该存在，其他元素不受影响，got=%v", res)
+	}
+	assertCounters(t, f, "13800138000", 0)
+	assertCounters(t, f, "13800138001", 1)
+}
+
+// TestRedisCountingFilter_RemoveNotAdded 删除没有添加过的元素，不修改任何计数器，计数器不会小于0
+func TestRedisCountingFilter_RemoveNotAdded(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	f := NewRedisCountingFilter(client, newTestKey(t, client), 1000, 0.001)
+
+	// 计数器还不存在
+	if ok, err := f.Remove(ctx, "13900139000"); err != nil || ok {
+		t.Fatalf("没有添加过的元素，不应该删除成功，ok=%v，err=%v", ok, err)
+	}
+	assertCounters(t, f, "13900139000", 0)
+
+	if err := f.AddMany(ctx, []string{"13800138000", "13800138001"}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := client.Get(ctx, f.key).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if ok, _ := f.Remove(ctx, "13900139000"); ok {
+			t.Fatal("没有添加过的元素，不应该删除成功")
+		}
+	}
+	after, _ := client.Get(ctx, f.key).Bytes()
+	if string(before) != string(after) {
+		t.Fatal("删除没有添加过的元素，不应该修改计数器")
+	}
+	assertCounters(t, f, "13800138000", 1)
+	assertCounters(t, f, "13800138001", 1)
+}
+
+// TestRedisCountingFilter_Saturation 计数器饱和后不会回绕成0，删除时也不会减少
+func TestRedisCountingFilter_Saturation(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	f := NewRedisCountingFilter(client, newTestKey(t, client), 1000, 0.001)
+
+	for i := 0; i < 20; i++ {
+		if err := f.Add(ctx, "13700137000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertCounters(t, f, "13700137000", maxCount)
+	for i := 0; i < 20; i++ {
+		if ok, err := f.Remove(ctx, "13700137000"); err != nil || !ok {
+			t.Fatalf("饱和的计数器不会减少，应该删除成功，ok=%v，err=%v", ok, err)
+		}
+	}
+	assertCounters(t, f, "13700137000", maxCount)
+	if ok, _ := f.MightContain(ctx, "13700137000"); !ok {
+		t.Fatal("计数器饱和后，元素应该一直存在")
+	}
+}
@@ -0,0 +1,25 @@
+package bloomFilter
+
+import (
+	"context"
+)
+
+// Filter 布隆过滤器的接口，redis的bitmap实现（BloomFilter、ScalableBloomFilter）和本地内存的实现（MemoryFilter）都实现了这个接口
+//
+//	热点路径可以先查询本地的过滤器，redis作为多个实例共享的数据源
+type Filter interface {
+	// Add 添加元素
+	Add(ctx context.Context, key string) error
+	// MightContain 判断元素是否可能存在，返回false表示一定不存在，返回true表示可能存在（有误判率）
+	MightContain(ctx context.Context, key string) (bool, error)
+	// AddMany 批量添加元素
+	AddMany(ctx context.Context, keys []string) error
+	// MightContainMany 批量判断元素是否可能存在，返回值和keys一一对应
+	MightContainMany(ctx context.Context, keys []string) ([]bool, error)
+}
+
+var (
+	_ Filter = (*BloomFilter)(nil)
+	_ Filter = (*MemoryFilter)(nil)
+	_ Filter = (*ScalableBloomFilter)(nil)
+)
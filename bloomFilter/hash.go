@@ -0,0 +1,49 @@
+package bloomFilter
+
+import (
+	"github.com/spaolacci/murmur3"
+	"math"
+)
+
+// maxBits redis的bitmap最大是2^32bit（512MB）
+const maxBits = 1 << 32
+
+// maxHashes 哈希函数的最大个数，对应的误判率约为2^-32，更多的哈希函数没有实际意义，只会增加每次读写的bit数
+const maxHashes = 32
+
+// EstimateParameters 根据预计的元素个数n和期望的误判率p，计算bitmap的大小m和哈希函数的个数k
+//
+//	m = -n * ln(p) / (ln2)^2
+//	k = m / n * ln2，最多maxHashes个
+//	n必须大于0，p必须在(0, 1)之间，否则panic（p<=0时m和k是无穷大）
+func EstimateParameters(n uint64, p float64) (m, k uint64) {
+	if n == 0 || !(p > 0 && p < 1) {
+		panic("bloomFilter: 预计的元素个数必须大于0，误判率必须在(0, 1)之间")
+	}
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = min(max(m, 1), maxBits)
+	k = uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	return m, min(max(k, 1), maxHashes)
+}
+
+// locations 使用双重哈希计算元素在bitmap中的k个位置
+//
+//	只计算一次murmur3的128位哈希值，拆分成h1和h2，第i个位置 = (h1 + i*h2) % m
+//	和使用k个独立的哈希函数相比，误判率几乎没有区别，但是只需要计算一次哈希
+func locations(data []byte, k, m uint64) []uint64 {
+	h1, h2 := murmur3.Sum128(data)
+	res := make([]uint64, k)
+	for i := uint64(0); i < k; i++ {
+		res[i] = (h1 + i*h2) % m
+	}
+	return res
+}
+
+// toBytes 将字符串转为字节序列
+func toBytes(keys []string) [][]byte {
+	res := make([][]byte, len(keys))
+	for i, key := range keys {
+		res[i] = []byte(key)
+	}
+	return res
+}
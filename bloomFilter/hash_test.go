@@ -0,0 +1,90 @@
+package bloomFilter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateParameters(t *testing.T) {
+	testCases := []struct {
+		name  string
+		n     uint64
+		p     float64
+		wantM uint64
+		wantK uint64
+	}{
+		{name: "1000万，0.1%", n: 10_000_000, p: 0.001, wantM: 143775876, wantK: 10},
+		{name: "1万，1%", n: 10_000, p: 0.01, wantM: 95851, wantK: 7},
+		{name: "超过bitmap的最大值", n: 10_000_000_000, p: 0.0001, wantM: maxBits, wantK: 1},
+		{name: "误判率非常小，限制哈希函数的个数", n: 1, p: 1e-300, wantM: 1438, wantK: maxHashes},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, k := EstimateParameters(tc.n, tc.p)
+			if m != tc.wantM || k != tc.wantK {
+				t.Fatalf("want m=%d k=%d，got m=%d k=%d", tc.wantM, tc.wantK, m, k)
+			}
+		})
+	}
+}
+
+// TestEstimateParameters_Invalid 误判率不在(0, 1)之间，或者元素个数为0时panic，而不是返回无穷大的m和k
+func TestEstimateParameters_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		n    uint64
+		p    float64
+	}{
+		{name: "误判率为0", n: 1, p: 0},
+		{name: "误判率为负数", n: 1, p: -1},
+		{name: "误判率为1", n: 1000, p: 1},
+		{name: "误判率为NaN", n: 1000, p: math.NaN()},
+		{name: "元素个数为0", n: 0, p: 0.01},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("参数不合法，应该panic，n=%d，p=%v", tc.n, tc.p)
+				}
+			}()
+			EstimateParameters(tc.n, tc.p)
+		})
+	}
+	// 使用EstimateParameters的构造函数同样panic
+	constructors := map[string]func(){
+		"redis":   func() { NewBloomFilterWithEstimates(nil, "bloom:test", 1, 0) },
+		"内存":      func() { NewMemoryFilter(1, 0) },
+		"redis计数": func() { NewRedisCountingFilter(nil, "bloom:test", 1, -1) },
+		"内存计数":    func() { NewMemoryCountingFilter(1, -1) },
+	}
+	for name, fn := range constructors {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("参数不合法，应该panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestLocations(t *testing.T) {
+	offsets := locations([]byte("13800138000"), 7, 1000)
+	if len(offsets) != 7 {
+		t.Fatalf("应该返回7个位置，got=%d", len(offsets))
+	}
+	for _, offset := range offsets {
+		if offset >= 1000 {
+			t.Fatalf("位置超出了bitmap的大小，got=%d", offset)
+		}
+	}
+	// 同一个元素的位置是固定的
+	again := locations([]byte("13800138000"), 7, 1000)
+	for i := range offsets {
+		if offsets[i] != again[i] {
+			t.Fatal("同一个元素计算出的位置不一致")
+		}
+	}
+}
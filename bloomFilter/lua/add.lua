@@ -0,0 +1,29 @@
+-- 向布隆过滤器中添加元素，设置bitmap中的k个bit
+-- 重建（Rebuild、Import）期间，重建锁存在时，同时写入影子key，
+-- 影子key替换bitmapKey之后，重建期间添加的元素不会丢失
+-- 返回值：1 同时写入了影子key，0 只写入了bitmapKey
+
+-- bitmap
+local key = KEYS[1]
+-- 影子key
+local shadow = KEYS[2]
+-- 重建锁，值是"m:k:token"
+local lock = KEYS[3]
+
+-- 当前过滤器的参数"m:k:"，只有参数和重建的过滤器相同时，写入影子key才有意义
+local params = ARGV[1]
+
+local mirror = 0
+local val = redis.call('GET', lock)
+if val and string.sub(val, 1, #params) == params then
+    mirror = 1
+end
+
+-- ARGV[2]开始是要设置的bit的位置
+for i = 2, #ARGV do
+    redis.call('SETBIT', key, ARGV[i], 1)
+    if mirror == 1 then
+        redis.call('SETBIT', shadow, ARGV[i], 1)
+    end
+end
+return mirror
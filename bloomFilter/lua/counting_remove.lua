@@ -0,0 +1,38 @@
+-- 从计数布隆过滤器中删除元素
+-- 使用 BITFIELD 读取k个4位计数器，有一个计数器为0时，元素一定不存在，不做任何修改
+-- 计数器饱和（15）后，无法知道真实的计数，保持15不变，避免把其他元素的计数减成0
+-- 返回值：1 删除成功，0 元素不存在
+
+-- 计数器所在的key
+local key = KEYS[1]
+
+-- 读取k个计数器，ARGV是计数器的下标
+local get = {}
+for i = 1, #ARGV do
+    table.insert(get, 'GET')
+    table.insert(get, 'u4')
+    table.insert(get, '#' .. ARGV[i])
+end
+local counters = redis.call('BITFIELD', key, unpack(get))
+
+for i = 1, #counters do
+    if counters[i] == 0 then
+        -- 元素一定不存在，删除不存在的元素会导致其他元素被误删
+        return 0
+    end
+end
+
+-- 计数器减1，已经饱和的计数器不修改
+local decr = {}
+for i = 1, #counters do
+    if counters[i] < 15 then
+        table.insert(decr, 'INCRBY')
+        table.insert(decr, 'u4')
+        table.insert(decr, '#' .. ARGV[i])
+        table.insert(decr, -1)
+    end
+end
+if #decr > 0 then
+    redis.call('BITFIELD', key, 'OVERFLOW', 'SAT', unpack(decr))
+end
+return 1
@@ -0,0 +1,11 @@
+-- 重建失败，删除影子key并释放重建锁
+-- 锁已经被其他人持有时，影子key属于其他人的重建，不做任何修改
+
+local shadow = KEYS[1]
+local lock = KEYS[2]
+
+if redis.call('GET', lock) ~= ARGV[1] then
+    return 0
+end
+redis.call('DEL', shadow, lock)
+return 1
@@ -0,0 +1,12 @@
+-- 获取重建锁，并删除上一次重建留下的影子key（比如重建的进程崩溃了）
+-- 加锁和删除影子key在同一个脚本中执行，加锁之后添加的元素写入影子key，不会被删除
+-- 返回值：1 加锁成功，0 其他人正在重建
+
+local shadow = KEYS[1]
+local lock = KEYS[2]
+
+if not redis.call('SET', lock, ARGV[1], 'NX', 'PX', ARGV[2]) then
+    return 0
+end
+redis.call('DEL', shadow)
+return 1
@@ -0,0 +1,10 @@
+-- 重建锁续期，锁还被自己持有时延长过期时间
+-- 返回值：1 续期成功，0 重建锁已经过期或者被其他人持有
+
+local lock = KEYS[1]
+
+if redis.call('GET', lock) ~= ARGV[1] then
+    return 0
+end
+redis.call('PEXPIRE', lock, ARGV[2])
+return 1
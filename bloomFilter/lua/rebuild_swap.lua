@@ -0,0 +1,19 @@
+-- 重建完成，使用影子key原子替换bitmapKey，并释放重建锁
+-- 替换和释放锁在同一个脚本中执行，替换之后添加的元素不会再写入影子key
+-- 影子key不存在时（没有任何元素），删除bitmapKey
+-- 返回值：1 替换成功，0 重建锁已经过期或者被其他人持有，不做任何修改
+
+local key = KEYS[1]
+local shadow = KEYS[2]
+local lock = KEYS[3]
+
+if redis.call('GET', lock) ~= ARGV[1] then
+    return 0
+end
+if redis.call('EXISTS', shadow) == 1 then
+    redis.call('RENAME', shadow, key)
+else
+    redis.call('DEL', key)
+end
+redis.call('DEL', lock)
+return 1
@@ -0,0 +1,84 @@
+-- 向可扩展布隆过滤器中添加元素
+-- 元数据保存在hash中：
+--     n0（第一层的容量）、p0（第一层的误判率）、r（误判率的收紧比例）、s（容量的增长倍数）、layers（层数）
+--     第i层：cap:i（容量）、m:i（bit数）、k:i（哈希函数的个数）、count:i（元素个数）
+-- 第i层的bitmap保存在 KEYS[2]..i 中，KEYS[2]和KEYS[1]使用相同的hash tag，拼接出的key和元数据在同一个slot
+-- 元素已经存在时不重复添加，避免重复的元素占用容量；当前层满了之后，创建一个新的层
+-- 返回值：1 添加成功，0 元素可能已经存在
+
+-- 元数据的key
+local meta = KEYS[1]
+-- bitmap的key的前缀
+local prefix = KEYS[2]
+
+-- 元素的哈希值，拆分成两个32位的整数，第j个位置 = (h1 + j*h2) % m
+local h1 = tonumber(ARGV[1])
+local h2 = tonumber(ARGV[2])
+
+-- 第一次添加元素时，保存参数，之后使用保存的参数
+redis.call('HSETNX', meta, 'n0', ARGV[3])
+redis.call('HSETNX', meta, 'p0', ARGV[4])
+redis.call('HSETNX', meta, 'r', ARGV[5])
+redis.call('HSETNX', meta, 's', ARGV[6])
+local params = redis.call('HMGET', meta, 'n0', 'p0', 'r', 's', 'layers')
+local n0 = tonumber(params[1])
+local p0 = tonumber(params[2])
+local r = tonumber(params[3])
+local s = tonumber(params[4])
+local layers = tonumber(params[5]) or 0
+
+-- 判断元素在第i层中是否存在
+local function contains(i, m, k)
+    local key = prefix .. i
+    for j = 0, k - 1 do
+        if redis.call('GETBIT', key, (h1 + j * h2) % m) == 0 then
+            return false
+        end
+    end
+    return true
+end
+
+for i = 0, layers - 1 do
+    local layer = redis.call('HMGET', meta, 'm:' .. i, 'k:' .. i)
+    if contains(i, tonumber(layer[1]), tonumber(layer[2])) then
+        return 0
+    end
+end
+
+-- 当前层
+local i = layers - 1
+local m, k, capacity, count
+if i >= 0 then
+    local layer = redis.call('HMGET', meta, 'm:' .. i, 'k:' .. i, 'cap:' .. i, 'count:' .. i)
+    m = tonumber(layer[1])
+    k = tonumber(layer[2])
+    capacity = tonumber(layer[3])
+    count = tonumber(layer[4])
+end
+
+if i < 0 or count >= capacity then
+    -- 创建新的层，容量是上一层的s倍，误判率是上一层的r倍
+    i = i + 1
+    capacity = math.floor(n0 * s ^ i)
+    local p = p0 * r ^ i
+    -- m = -n * ln(p) / (ln2)^2，redis的bitmap最大是2^32bit
+    m = math.ceil(-capacity * math.log(p) / (math.log(2) ^ 2))
+    if m > 4294967296 then
+        m = 4294967296
+    end
+    -- 误判率为p时，每层使用 log2(1/p) 个哈希函数，最多32个（见hash.go的maxHashes）
+    -- 层数很多时p非常小，不限制的话，每次添加元素都要设置大量的bit
+    k = math.ceil(-math.log(p) / math.log(2))
+    if k > 32 then
+        k = 32
+    end
+    redis.call('HSET', meta, 'layers', i + 1,
+            'cap:' .. i, capacity, 'm:' .. i, m, 'k:' .. i, k, 'count:' .. i, 0)
+end
+
+local key = prefix .. i
+for j = 0, k - 1 do
+    redis.call('SETBIT', key, (h1 + j * h2) % m, 1)
+end
+redis.call('HINCRBY', meta, 'count:' .. i, 1)
+return 1
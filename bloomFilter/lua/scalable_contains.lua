@@ -0,0 +1,27 @@
+-- 判断元素是否在可扩展布隆过滤器中，依次检查每一层，有一层包含元素时返回1
+-- 元数据和参数的说明见 scalable_add.lua
+
+local meta = KEYS[1]
+local prefix = KEYS[2]
+local h1 = tonumber(ARGV[1])
+local h2 = tonumber(ARGV[2])
+
+local layers = tonumber(redis.call('HGET', meta, 'layers')) or 0
+-- 从最新的层开始检查，最新的层保存的元素最多
+for i = layers - 1, 0, -1 do
+    local layer = redis.call('HMGET', meta, 'm:' .. i, 'k:' .. i)
+    local m = tonumber(layer[1])
+    local k = tonumber(layer[2])
+    local key = prefix .. i
+    local found = true
+    for j = 0, k - 1 do
+        if redis.call('GETBIT', key, (h1 + j * h2) % m) == 0 then
+            found = false
+            break
+        end
+    end
+    if found then
+        return 1
+    end
+end
+return 0
@@ -0,0 +1,73 @@
+package bloomFilter
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// MemoryFilter 本地内存的布隆过滤器，只对当前实例生效
+//
+//	使用[]atomic.Uint64保存bitset，添加元素使用原子的Or操作，读写都不需要加锁，可以被多个协程并发使用
+//	也可以在单元测试中代替redis的布隆过滤器
+type MemoryFilter struct {
+	bits []atomic.Uint64
+	m    uint64 // bitset的大小（bit数）
+	k    uint64 // 哈希函数的个数
+}
+
+// NewMemoryFilter 根据预计的元素个数n和期望的误判率p创建本地内存的布隆过滤器
+//
+//	n为0或者p不在(0, 1)之间时panic
+func NewMemoryFilter(n uint64, p float64) *MemoryFilter {
+	m, k := EstimateParameters(n, p)
+	return &MemoryFilter{
+		bits: make([]atomic.Uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add 添加元素
+func (f *MemoryFilter) Add(ctx context.Context, key string) error {
+	f.AddBytes([]byte(key))
+	return nil
+}
+
+// AddBytes 添加任意字节序列的元素
+func (f *MemoryFilter) AddBytes(data []byte) {
+	for _, offset := range locations(data, f.k, f.m) {
+		f.bits[offset/64].Or(1 << (offset % 64))
+	}
+}
+
+// MightContain 判断元素是否可能存在，返回false表示一定不存在
+func (f *MemoryFilter) MightContain(ctx context.Context, key string) (bool, error) {
+	return f.MightContainBytes([]byte(key)), nil
+}
+
+// MightContainBytes 判断任意字节序列的元素是否可能存在
+func (f *MemoryFilter) MightContainBytes(data []byte) bool {
+	for _, offset := range locations(data, f.k, f.m) {
+		if f.bits[offset/64].Load()&(1<<(offset%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AddMany 批量添加元素
+func (f *MemoryFilter) AddMany(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		f.AddBytes([]byte(key))
+	}
+	return nil
+}
+
+// MightContainMany 批量判断元素是否可能存在，返回值和keys一一对应
+func (f *MemoryFilter) MightContainMany(ctx context.Context, keys []string) ([]bool, error) {
+	res := make([]bool, len(keys))
+	for i, key := range keys {
+		res[i] = f.MightContainBytes([]byte(key))
+	}
+	return res, nil
+}
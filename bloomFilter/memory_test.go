@@ -0,0 +1,59 @@
+package bloomFilter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMemoryFilter(t *testing.T) {
+	ctx := context.Background()
+	f := NewMemoryFilter(10000, 0.01)
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	// 并发添加元素
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(batch []string) {
+			defer wg.Done()
+			_ = f.AddMany(ctx, batch)
+		}(keys[i*1000 : (i+1)*1000])
+	}
+	wg.Wait()
+
+	// 添加过的元素，一定存在
+	res, _ := f.MightContainMany(ctx, keys)
+	for i, ok := range res {
+		if !ok {
+			t.Fatalf("添加过的元素应该存在，key=%s", keys[i])
+		}
+	}
+	// 没有添加过的元素，误判率接近1%
+	falsePositive := 0
+	for i := 0; i < 10000; i++ {
+		if ok, _ := f.MightContain(ctx, "order:"+strconv.Itoa(i)); ok {
+			falsePositive++
+		}
+	}
+	if rate := float64(falsePositive) / 10000; rate > 0.02 {
+		t.Fatalf("误判率过高，got=%.4f", rate)
+	}
+}
+
+func TestTypedBloomFilter_Memory(t *testing.T) {
+	ctx := context.Background()
+	f := NewTypedBloomFilter[int64](NewMemoryFilter(100, 0.01), Int64Encoder)
+	if err := f.Add(ctx, 42); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := f.MightContain(ctx, 42); !ok {
+		t.Fatal("添加过的元素应该存在")
+	}
+	if ok, _ := f.MightContain(ctx, 43); ok {
+		t.Fatal("没有添加过的元素不应该存在")
+	}
+}
@@ -0,0 +1,84 @@
+package bloomFilter
+
+import (
+	"GoToolkit/loggerx"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// StatsCollector 采集布隆过滤器的统计信息，每个过滤器使用bitmapKey作为key标签
+//
+//	Prometheus每次采集时执行BITCOUNT，bitmap很大时需要调大采集间隔
+type StatsCollector struct {
+	filters []*BloomFilter
+	timeout time.Duration // 每个过滤器执行BITCOUNT的超时时间
+	logger  loggerx.Logger
+
+	bits              *prometheus.Desc
+	bitsSet           *prometheus.Desc
+	fillRatio         *prometheus.Desc
+	estimatedCount    *prometheus.Desc
+	falsePositiveRate *prometheus.Desc
+}
+
+// NewStatsCollector 创建统计信息的采集器，并注册到prometheus中
+//
+//	注册失败时返回error，相同的namespace、subsystem、instanceId已经注册过时，返回prometheus.AlreadyRegisteredError，
+//	不会忽略重复注册，否则后注册的过滤器永远不会被采集
+func NewStatsCollector(namespace, subsystem, instanceId string, logger loggerx.Logger,
+	filters ...*BloomFilter) (*StatsCollector, error) {
+	c := newStatsCollector(namespace, subsystem, instanceId, logger, filters...)
+	if err := prometheus.Register(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newStatsCollector(namespace, subsystem, instanceId string, logger loggerx.Logger,
+	filters ...*BloomFilter) *StatsCollector {
+	// 常量标签：实例ID，使用id来区分不同实例
+	constLabels := prometheus.Labels{"instance_id": instanceId}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name),
+			help, []string{"key"}, constLabels)
+	}
+	return &StatsCollector{
+		filters:           filters,
+		timeout:           time.Second,
+		logger:            logger,
+		bits:              desc("bloom_filter_bits", "bitmap的大小（bit数）"),
+		bitsSet:           desc("bloom_filter_bits_set", "值为1的bit数"),
+		fillRatio:         desc("bloom_filter_fill_ratio", "填充率"),
+		estimatedCount:    desc("bloom_filter_estimated_count", "估算的元素个数"),
+		falsePositiveRate: desc("bloom_filter_false_positive_rate", "当前估算的误判率"),
+	}
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bits
+	ch <- c.bitsSet
+	ch <- c.fillRatio
+	ch <- c.estimatedCount
+	ch <- c.falsePositiveRate
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, f := range c.filters {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		stats, err := f.Stats(ctx)
+		cancel()
+		if err != nil {
+			// 采集失败，跳过这个过滤器并记录日志，不影响其他过滤器
+			// 不能上报InvalidMetric，否则整个/metrics请求都会失败
+			c.logger.Error("采集布隆过滤器的统计信息失败",
+				loggerx.String("key", f.bitmapKey), loggerx.Error(err))
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.bits, prometheus.GaugeValue, float64(stats.Bits), f.bitmapKey)
+		ch <- prometheus.MustNewConstMetric(c.bitsSet, prometheus.GaugeValue, float64(stats.BitsSet), f.bitmapKey)
+		ch <- prometheus.MustNewConstMetric(c.fillRatio, prometheus.GaugeValue, stats.FillRatio, f.bitmapKey)
+		ch <- prometheus.MustNewConstMetric(c.estimatedCount, prometheus.GaugeValue, stats.EstimatedCount, f.bitmapKey)
+		ch <- prometheus.MustNewConstMetric(c.falsePositiveRate, prometheus.GaugeValue, stats.FalsePositiveRate, f.bitmapKey)
+	}
+}
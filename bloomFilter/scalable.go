@@ -0,0 +1,202 @@
+package bloomFilter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/spaolacci/murmur3"
+	"strconv"
+)
+
+var (
+	//go:embed lua/scalable_add.lua
+	luaScalableAdd string
+	//go:embed lua/scalable_contains.lua
+	luaScalableContains string
+
+	scalableAddScript      = redis.NewScript(luaScalableAdd)
+	scalableContainsScript = redis.NewScript(luaScalableContains)
+)
+
+// ScalableBloomFilter 可扩展布隆过滤器，不需要预先知道元素的个数
+//
+//	由多层布隆过滤器组成，当前层的元素个数达到容量后，创建一个新的层：
+//	第i层的容量 = n0 * s^i，误判率 = p0 * r^i，其中p0 = p * (1-r)
+//	总的误判率 <= p0 + p0*r + p0*r^2 + ... = p0 / (1-r) = p，元素个数增加时，误判率仍然不会超过p
+//	只在需要时分配bitmap，不会像固定大小的2^32bit的bitmap一样占用512MB的内存
+//
+//	元数据（层数、每层的参数和元素个数）保存在redis的hash中，和bitmap使用相同的hash tag（{key}），
+//	在redis集群中会被分配到同一个slot，可以在一个lua脚本中访问；
+//	层数在脚本中才能确定，无法提前在KEYS中传入每一层的bitmap，脚本使用KEYS[2]（bitmap的key的前缀）拼接出每一层的key
+type ScalableBloomFilter struct {
+	cmd     redis.Cmdable
+	metaKey string  // 元数据的key
+	prefix  string  // bitmap的key的前缀，第i层的bitmap是prefix+i
+	n0      uint64  // 第一层的容量
+	p0      float64 // 第一层的误判率
+	r       float64 // 误判率的收紧比例
+	s       float64 // 容量的增长倍数
+}
+
+// ScalableOption 可扩展布隆过滤器的配置选项
+type ScalableOption func(*ScalableBloomFilter)
+
+// WithGrowth 设置每一层容量的增长倍数，默认是2，必须大于1
+func WithGrowth(s float64) ScalableOption {
+	return func(f *ScalableBloomFilter) {
+		f.s = s
+	}
+}
+
+// WithTightening 设置每一层误判率的收紧比例，默认是0.8，取值范围是(0, 1)
+func WithTightening(r float64) ScalableOption {
+	return func(f *ScalableBloomFilter) {
+		f.r = r
+	}
+}
+
+// NewScalableBloomFilter 创建可扩展布隆过滤器
+//
+//	n0 第一层的容量，p 总的误判率
+//	参数在第一次添加元素时保存到redis中，之后使用redis中保存的参数，修改参数需要重建过滤器，
+//	所以参数不合法时直接panic，不合法的参数一旦保存到redis中，过滤器就永久不可用了：
+//	p和收紧比例必须在(0, 1)之间，否则第一层的误判率为0，哈希函数的个数是无穷大；
+//	增长倍数必须大于1，否则后面的层容量为0；
+//	key不能为空，也不能以}开头，否则hash tag（{key}）为空，redis集群使用整个key计算slot，元数据和bitmap不在同一个slot
+func NewScalableBloomFilter(cmd redis.Cmdable, key string, n0 uint64, p float64,
+	opts ...ScalableOption) *ScalableBloomFilter {
+	if key == "" || key[0] == '}' {
+		panic("bloomFilter: 可扩展布隆过滤器的key不能为空，也不能以}开头")
+	}
+	f := &ScalableBloomFilter{
+		cmd:     cmd,
+		metaKey: "{" + key + "}:meta",
+		prefix:  "{" + key + "}:layer:",
+		n0:      max(n0, 1),
+		r:       0.8,
+		s:       2,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if !(p > 0 && p < 1) || !(f.r > 0 && f.r < 1) || !(f.s > 1) {
+		panic("bloomFilter: 误判率和收紧比例必须在(0, 1)之间，增长倍数必须大于1")
+	}
+	f.p0 = p * (1 - f.r)
+	return f
+}
+
+// Add 添加元素，元素已经存在时不会重复添加
+func (f *ScalableBloomFilter) Add(ctx context.Context, key string) error {
+	return f.AddMany(ctx, []string{key})
+}
+
+// AddMany 批量添加元素，使用pipeline在一次网络往返中完成
+func (f *ScalableBloomFilter) AddMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return pipelineScript(ctx, f.cmd, scalableAddScript, func(pipe redis.Pipeliner) {
+		for _, key := range keys {
+			h1, h2 := hash32([]byte(key))
+			scalableAddScript.EvalSha(ctx, pipe, []string{f.metaKey, f.prefix}, h1, h2,
+				f.n0, strconv.FormatFloat(f.p0, 'g', -1, 64),
+				strconv.FormatFloat(f.r, 'g', -1, 64), strconv.FormatFloat(f.s, 'g', -1, 64))
+		}
+	})
+}
+
+// MightContain 判断元素是否可能存在，返回false表示一定不存在
+func (f *ScalableBloomFilter) MightContain(ctx context.Context, key string) (bool, error) {
+	res, err := f.MightContainMany(ctx, []string{key})
+	if err != nil {
+		return false, err
+	}
+	return res[0], nil
+}
+
+// MightContainMany 批量判断元素是否可能存在，返回值和keys一一对应
+func (f *ScalableBloomFilter) MightContainMany(ctx context.Context, keys []string) ([]bool, error) {
+	res := make([]bool, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+	cmds := make([]*redis.Cmd, len(keys))
+	err := pipelineScript(ctx, f.cmd, scalableContainsScript, func(pipe redis.Pipeliner) {
+		for i, key := range keys {
+			h1, h2 := hash32([]byte(key))
+			cmds[i] = scalableContainsScript.EvalSha(ctx, pipe, []string{f.metaKey, f.prefix}, h1, h2)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		v, err := cmd.Int()
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v == 1
+	}
+	return res, nil
+}
+
+// LayerInfo 一层布隆过滤器的信息
+type LayerInfo struct {
+	Capacity uint64 // 容量
+	Count    uint64 // 元素个数
+	M        uint64 // bit数
+	K        uint64 // 哈希函数的个数
+}
+
+// Layers 读取每一层的信息，过滤器为空时返回nil
+func (f *ScalableBloomFilter) Layers(ctx context.Context) ([]LayerInfo, error) {
+	meta, err := f.cmd.HGetAll(ctx, f.metaKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	layers, _ := strconv.Atoi(meta["layers"])
+	res := make([]LayerInfo, layers)
+	for i := range res {
+		fields := []*uint64{&res[i].Capacity, &res[i].Count, &res[i].M, &res[i].K}
+		for j, name := range []string{"cap", "count", "m", "k"} {
+			val, err := strconv.ParseUint(meta[name+":"+strconv.Itoa(i)], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("可扩展布隆过滤器的元数据不合法，layer：%d，field：%s，%w", i, name, err)
+			}
+			*fields[j] = val
+		}
+	}
+	return res, nil
+}
+
+// pipelineScript 在pipeline中使用EVALSHA执行lua脚本
+//
+//	pipeline中的命令不会在NOSCRIPT时自动改用EVAL，redis中没有缓存脚本时，先加载脚本再重新执行一次
+//	没有缓存脚本时，pipeline中所有的EVALSHA都会失败，不会有脚本被执行，重新执行是安全的
+func pipelineScript(ctx context.Context, cmd redis.Cmdable, script *redis.Script,
+	fn func(pipe redis.Pipeliner)) error {
+	_, err := cmd.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fn(pipe)
+		return nil
+	})
+	if err == nil || !redis.HasErrorPrefix(err, "NOSCRIPT") {
+		return err
+	}
+	if err = script.Load(ctx, cmd).Err(); err != nil {
+		return err
+	}
+	_, err = cmd.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fn(pipe)
+		return nil
+	})
+	return err
+}
+
+// hash32 计算元素的murmur3哈希值，拆分成两个32位的整数，lua中的数字是double，64位的整数会丢失精度
+func hash32(data []byte) (uint32, uint32) {
+	h1, h2 := murmur3.Sum128(data)
+	// h2为0时，所有的位置都相同
+	return uint32(h1), uint32(h2) | 1
+}
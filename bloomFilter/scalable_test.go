@@ -0,0 +1,208 @@
+package bloomFilter
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newScalableKey 生成可扩展布隆过滤器使用的key，测试结束后删除元数据和每一层的bitmap
+func newScalableKey(t *testing.T, client redis.Cmdable,
+	f func(key string) *ScalableBloomFilter) (string, *ScalableBloomFilter) {
+	key := "bloom:test:" + uuid.NewString()
+	filter := f(key)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys := []string{filter.metaKey}
+		for i := 0; i < 10; i++ {
+			keys = append(keys, filter.prefix+strconv.Itoa(i))
+		}
+		client.Del(ctx, keys...)
+	})
+	return key, filter
+}
+
+// TestScalableBloomFilter_Growth 元素个数超过第一层的容量后，按照增长倍数创建新的层，添加过的元素一定存在
+func TestScalableBloomFilter_Growth(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	key, f := newScalableKey(t, client, func(key string) *ScalableBloomFilter {
+		return NewScalableBloomFilter(client, key, 100, 0.01)
+	})
+	keys := make([]string, 650)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	for i := 0; i < len(keys); i += 100 {
+		if err := f.AddMany(ctx, keys[i:min(i+100, len(keys))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	layers, err := f.Layers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每一层的容量是上一层的2倍：100 + 200 + 400
+	if len(layers) != 3 {
+		t.Fatalf("应该有3层，got=%+v", layers)
+	}
+	var total uint64
+	p := 0.01 * (1 - 0.8)
+	for i, layer := range layers {
+		wantCap := uint64(100) << i
+		wantM, _ := EstimateParameters(wantCap, p)
+		if layer.Capacity != wantCap || layer.M != wantM || layer.K == 0 {
+			t.Fatalf("第%d层的参数错误，want cap=%d m=%d，got=%+v", i, wantCap, wantM, layer)
+		}
+		if i < len(layers)-1 && layer.Count != layer.Capacity {
+			t.Fatalf("第%d层满了之后才会创建新的层，got=%+v", i, layer)
+		}
+		total += layer.Count
+		p *= 0.8
+	}
+	// 被误判为已经存在的元素不会重复添加，元素个数可能略少于添加的次数
+	if total > uint64(len(keys)) || total < uint64(len(keys))-10 {
+		t.Fatalf("元素个数错误，got=%d", total)
+	}
+
+	// 添加过的元素，一定存在
+	res, err := f.MightContainMany(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range res {
+		if !ok {
+			t.Fatalf("添加过的元素应该存在，key=%s", keys[i])
+		}
+	}
+
+	// 参数保存在redis中，使用不同的参数创建过滤器，仍然使用第一次保存的参数
+	meta, err := client.HGetAll(ctx, f.metaKey).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["n0"] != "100" || meta["r"] != "0.8" || meta["s"] != "2" || meta["layers"] != "3" {
+		t.Fatalf("元数据错误，got=%v", meta)
+	}
+	other := NewScalableBloomFilter(client, key, 10, 0.1, WithGrowth(4))
+	if err = other.AddMany(ctx, []string{"order:1", "order:2"}); err != nil {
+		t.Fatal(err)
+	}
+	layers, _ = other.Layers(ctx)
+	if len(layers) != 3 || layers[2].Capacity != 400 {
+		t.Fatalf("应该使用redis中保存的参数，got=%+v", layers)
+	}
+}
+
+// TestScalableBloomFilter_NoScript redis的脚本缓存被清空后，pipeline中的EVALSHA失败，重新加载脚本后执行
+func TestScalableBloomFilter_NoScript(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	_, f := newScalableKey(t, client, func(key string) *ScalableBloomFilter {
+		return NewScalableBloomFilter(client, key, 100, 0.01)
+	})
+	if err := client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddMany(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("没有缓存脚本时，应该加载脚本后重新执行，err=%v", err)
+	}
+	if err := client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.MightContainMany(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("没有缓存脚本时，应该加载脚本后重新执行，err=%v", err)
+	}
+	if !res[0] || !res[1] || res[2] {
+		t.Fatalf("want=[true true false]，got=%v", res)
+	}
+	// 脚本只执行了一次，元素没有被重复添加
+	layers, _ := f.Layers(ctx)
+	if len(layers) != 1 || layers[0].Count != 2 {
+		t.Fatalf("元素个数错误，got=%+v", layers)
+	}
+}
+
+// TestNewScalableBloomFilter_Invalid 参数不合法时，创建过滤器直接panic，不合法的参数不会保存到redis中
+func TestNewScalableBloomFilter_Invalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		new  func()
+	}{
+		{name: "误判率为0", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, 0) }},
+		{name: "误判率为负数", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, -0.01) }},
+		{name: "误判率为1", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, 1) }},
+		{name: "收紧比例为0", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, 0.01, WithTightening(0)) }},
+		{name: "收紧比例为1", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, 0.01, WithTightening(1)) }},
+		{name: "增长倍数为1", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, 0.01, WithGrowth(1)) }},
+		{name: "增长倍数小于1", new: func() { NewScalableBloomFilter(nil, "bloom:test", 100, 0.01, WithGrowth(0.5)) }},
+		{name: "key为空", new: func() { NewScalableBloomFilter(nil, "", 100, 0.01) }},
+		{name: "key以}开头，hash tag为空", new: func() { NewScalableBloomFilter(nil, "}user", 100, 0.01) }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("参数不合法，应该panic")
+				}
+			}()
+			tc.new()
+		})
+	}
+}
+
+// TestScalableBloomFilter_MaxHashes 误判率非常小时，每一层的哈希函数最多maxHashes个
+func TestScalableBloomFilter_MaxHashes(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	_, f := newScalableKey(t, client, func(key string) *ScalableBloomFilter {
+		return NewScalableBloomFilter(client, key, 10, 1e-30)
+	})
+	if err := f.AddMany(ctx, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := f.Layers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 || layers[0].K != maxHashes {
+		t.Fatalf("哈希函数的个数应该是%d，got=%+v", maxHashes, layers)
+	}
+	if ok, _ := f.MightContain(ctx, "a"); !ok {
+		t.Fatal("添加过的元素应该存在")
+	}
+}
+
+// hashTag redis集群计算slot时使用的部分：第一个{和之后第一个}之间的内容不为空时使用hash tag，否则使用整个key
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// TestScalableBloomFilter_HashTag 元数据和每一层的bitmap在redis集群的同一个slot中
+func TestScalableBloomFilter_HashTag(t *testing.T) {
+	for _, key := range []string{"user", "bloom:user", "a{b}c", "a}", "{"} {
+		f := NewScalableBloomFilter(nil, key, 100, 0.01)
+		tag := hashTag(f.metaKey)
+		if tag == f.metaKey {
+			t.Fatalf("元数据的key应该使用hash tag，key=%q，got=%q", key, f.metaKey)
+		}
+		for i := 0; i < 3; i++ {
+			if got := hashTag(f.prefix + strconv.Itoa(i)); got != tag {
+				t.Fatalf("第%d层的hash tag和元数据不一致，key=%q，want=%q，got=%q", i, key, tag, got)
+			}
+		}
+	}
+}
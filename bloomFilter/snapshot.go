@@ -0,0 +1,229 @@
+package bloomFilter
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"iter"
+	"strconv"
+	"time"
+)
+
+// snapshotMagic 快照文件的魔数
+var snapshotMagic = [4]byte{'B', 'L', 'M', 'F'}
+
+// snapshotVersion 快照文件格式的版本
+const snapshotVersion = 1
+
+// chunkSize 每次读写bitmap的字节数，避免一次读写512MB的大key阻塞redis
+const chunkSize = 1 << 20
+
+// rebuildBatchSize 重建时每批添加的元素个数
+const rebuildBatchSize = 1000
+
+// rebuildLockTTL 重建锁的过期时间，每写入一批数据续期一次，重建的进程崩溃时，锁过期后可以重新重建
+const rebuildLockTTL = 30 * time.Second
+
+var (
+	ErrInvalidSnapshot = errors.New("bloomFilter: 快照文件不合法")
+	ErrRebuilding      = errors.New("bloomFilter: 布隆过滤器正在重建或者导入")
+	errRebuildLockLost = errors.New("bloomFilter: 重建锁已经过期，重建失败")
+)
+
+var (
+	//go:embed lua/rebuild_lock.lua
+	luaRebuildLock string
+	//go:embed lua/rebuild_renew.lua
+	luaRebuildRenew string
+	//go:embed lua/rebuild_swap.lua
+	luaRebuildSwap string
+	//go:embed lua/rebuild_abort.lua
+	luaRebuildAbort string
+
+	rebuildLockScript  = redis.NewScript(luaRebuildLock)
+	rebuildRenewScript = redis.NewScript(luaRebuildRenew)
+	rebuildSwapScript  = redis.NewScript(luaRebuildSwap)
+	rebuildAbortScript = redis.NewScript(luaRebuildAbort)
+)
+
+// snapshotHeader 快照文件的头部，之后是size个字节的bitmap
+type snapshotHeader struct {
+	Magic   [4]byte
+	Version uint8
+	M       uint64 // bitmap的大小（bit数）
+	K       uint64 // 哈希函数的个数
+	Size    uint64 // bitmap的字节数
+}
+
+// Export 导出布隆过滤器的参数和bitmap到w，使用GETRANGE分块读取bitmap
+//
+//	导出过程中仍然可以写入，快照不保证是某个时间点的一致状态，元素最多只会多，不会少（bit只会从0变成1）
+func (b *BloomFilter) Export(ctx context.Context, w io.Writer) error {
+	size, err := b.cmd.StrLen(ctx, b.bitmapKey).Result()
+	if err != nil {
+		return err
+	}
+	header := snapshotHeader{
+		Magic:   snapshotMagic,
+		Version: snapshotVersion,
+		M:       b.m,
+		K:       b.k,
+		Size:    uint64(size),
+	}
+	if err = binary.Write(w, binary.BigEndian, header); err != nil {
+		return err
+	}
+	for start := int64(0); start < size; start += chunkSize {
+		end := min(start+chunkSize, size) - 1
+		chunk, err := b.cmd.GetRange(ctx, b.bitmapKey, start, end).Bytes()
+		if err != nil {
+			return err
+		}
+		// 导出过程中bitmap变长时，只导出STRLEN时的长度
+		if _, err = w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import 从r导入快照，使用快照中的参数创建布隆过滤器
+//
+//	bitmap先使用SETRANGE分块写入影子key，全部写入之后再使用RENAME原子替换bitmapKey，
+//	导入过程中原来的过滤器可以正常读写，导入完成后原来的数据（包括导入期间添加的元素）会被覆盖
+//	导入和重建使用同一把重建锁，正在重建或者导入时返回ErrRebuilding
+//	快照中的参数必须是EstimateParameters可能返回的值：M在[1, maxBits]之间，K在[1, maxHashes]之间，
+//	否则返回ErrInvalidSnapshot，避免被篡改的快照让每次Add、MightContain计算、写入数十亿个位置
+func Import(ctx context.Context, cmd redis.Cmdable, bitmapKey string, r io.Reader) (*BloomFilter, error) {
+	var header snapshotHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w，%w", ErrInvalidSnapshot, err)
+	}
+	if header.Magic != snapshotMagic || header.Version != snapshotVersion ||
+		header.M == 0 || header.M > maxBits || header.K == 0 || header.K > maxHashes ||
+		header.Size > (header.M+7)/8 {
+		return nil, ErrInvalidSnapshot
+	}
+	b := &BloomFilter{
+		cmd:       cmd,
+		bitmapKey: bitmapKey,
+		m:         header.M,
+		k:         header.K,
+	}
+	// SETRANGE会覆盖同时写入影子key的bit，导入期间添加的元素不写入影子key
+	err := b.rebuild(ctx, "import:", func(shadow string, renew func() error) error {
+		buf := make([]byte, chunkSize)
+		for offset := uint64(0); offset < header.Size; offset += chunkSize {
+			n := min(chunkSize, header.Size-offset)
+			if _, err := io.ReadFull(r, buf[:n]); err != nil {
+				return fmt.Errorf("%w，%w", ErrInvalidSnapshot, err)
+			}
+			// bitmap中大部分字节都是0时，跳过全是0的块，SETRANGE会自动用0填充
+			if bytes.Count(buf[:n], []byte{0}) == int(n) && offset+n < header.Size {
+				continue
+			}
+			if err := cmd.SetRange(ctx, shadow, int64(offset), string(buf[:n])).Err(); err != nil {
+				return err
+			}
+			if err := renew(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Rebuild 从数据源重建布隆过滤器，比如过滤器被污染时
+//
+//	keys是调用方提供的迭代器，比如分页查询数据库中所有的手机号，迭代器返回error时停止重建
+//	元素先批量写入影子key，全部写入之后再使用RENAME原子替换bitmapKey，重建过程中原来的过滤器可以正常读写
+//	重建期间，其他实例通过Add、AddMany添加的元素会同时写入影子key，替换之后不会丢失
+//	同一时间只能有一个重建（或者导入），使用SET NX加锁，其他人正在重建时返回ErrRebuilding
+//
+//	影子key是{bitmapKey}:shadow，重建锁是{bitmapKey}:rebuilding，
+//	在redis集群中和bitmapKey在同一个slot（bitmapKey中不能包含{}）
+func (b *BloomFilter) Rebuild(ctx context.Context, keys iter.Seq2[string, error]) error {
+	return b.rebuild(ctx, b.params(), func(shadow string, renew func() error) error {
+		batch := make([][]byte, 0, rebuildBatchSize)
+		for key, err := range keys {
+			if err != nil {
+				return err
+			}
+			batch = append(batch, []byte(key))
+			if len(batch) < rebuildBatchSize {
+				continue
+			}
+			if err = b.setBits(ctx, shadow, batch); err != nil {
+				return err
+			}
+			if err = renew(); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		return b.setBits(ctx, shadow, batch)
+	})
+}
+
+// rebuild 持有重建锁，执行fill写入影子key，完成后使用影子key原子替换bitmapKey并释放锁
+//
+//	prefix 重建锁的值的前缀，和过滤器的参数（params）相同时，Add会同时写入影子key
+//	fill   写入影子key，每写入一批数据调用一次renew，给重建锁续期
+func (b *BloomFilter) rebuild(ctx context.Context, prefix string,
+	fill func(shadow string, renew func() error) error) error {
+	shadow, lock := b.shadowKey(), b.lockKey()
+	val := prefix + uuid.NewString()
+	ttl := rebuildLockTTL.Milliseconds()
+	ok, err := rebuildLockScript.Run(ctx, b.cmd, []string{shadow, lock}, val, ttl).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRebuilding
+	}
+	renew := func() error {
+		ok, err := rebuildRenewScript.Run(ctx, b.cmd, []string{lock}, val, ttl).Bool()
+		if err == nil && !ok {
+			err = errRebuildLockLost
+		}
+		return err
+	}
+	if err = fill(shadow, renew); err == nil {
+		ok, err = rebuildSwapScript.Run(ctx, b.cmd, []string{b.bitmapKey, shadow, lock}, val).Bool()
+		if err == nil && !ok {
+			// 锁已经过期，其他人可能正在使用影子key，不能删除
+			return errRebuildLockLost
+		}
+		return err
+	}
+	// 重建失败，删除影子key并释放锁；ctx可能已经被取消了，使用新的ctx
+	abortCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rebuildAbortScript.Run(abortCtx, b.cmd, []string{shadow, lock}, val)
+	return err
+}
+
+// params 过滤器的参数"m:k:"，作为重建锁的值的前缀
+func (b *BloomFilter) params() string {
+	return strconv.FormatUint(b.m, 10) + ":" + strconv.FormatUint(b.k, 10) + ":"
+}
+
+// shadowKey 导入、重建时使用的影子key
+func (b *BloomFilter) shadowKey() string {
+	return "{" + b.bitmapKey + "}:shadow"
+}
+
+// lockKey 导入、重建时使用的重建锁
+func (b *BloomFilter) lockKey() string {
+	return "{" + b.bitmapKey + "}:rebuilding"
+}
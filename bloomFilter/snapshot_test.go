@@ -0,0 +1,227 @@
+package bloomFilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"iter"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// rangeKeys 生成prefix:0 ~ prefix:n-1
+func rangeKeys(prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = prefix + ":" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// assertContains 添加过的元素一定存在
+func assertContains(t *testing.T, f Filter, keys []string) {
+	t.Helper()
+	res, err := f.MightContainMany(context.Background(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range res {
+		if !ok {
+			t.Fatalf("添加过的元素应该存在，key=%s", keys[i])
+		}
+	}
+}
+
+// newRebuildFilter 创建测试使用的布隆过滤器，测试结束后删除bitmap、影子key和重建锁
+func newRebuildFilter(t *testing.T, n uint64) *BloomFilter {
+	client := newRedisClient(t)
+	f := NewBloomFilterWithEstimates(client, newTestKey(t, client), n, 0.01)
+	t.Cleanup(func() {
+		client.Del(context.Background(), f.shadowKey(), f.lockKey())
+	})
+	return f
+}
+
+// TestBloomFilter_ExportImport bitmap超过一个分块时，导出之后导入，bitmap和参数保持不变
+func TestBloomFilter_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	f := newRebuildFilter(t, 1_000_000)
+	keys := rangeKeys("user", 20)
+	if err := f.AddMany(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.Export(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	target := newTestKey(t, f.cmd)
+	imported, err := Import(ctx, f.cmd, target, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.m != f.m || imported.k != f.k {
+		t.Fatalf("应该使用快照中的参数，want m=%d k=%d，got m=%d k=%d", f.m, f.k, imported.m, imported.k)
+	}
+	assertContains(t, imported, keys)
+	want, _ := f.Stats(ctx)
+	got, _ := imported.Stats(ctx)
+	if got.BitsSet != want.BitsSet {
+		t.Fatalf("导入后的bitmap不一致，want=%d，got=%d", want.BitsSet, got.BitsSet)
+	}
+	if n, _ := f.cmd.Exists(ctx, imported.shadowKey(), imported.lockKey()).Result(); n != 0 {
+		t.Fatal("导入完成后应该删除影子key和重建锁")
+	}
+
+	// 快照被截断、魔数错误
+	truncated := buf.Bytes()[:buf.Len()/2]
+	if _, err = Import(ctx, f.cmd, target, bytes.NewReader(truncated)); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("want=%v，got=%v", ErrInvalidSnapshot, err)
+	}
+	assertContains(t, imported, keys)
+	if _, err = Import(ctx, f.cmd, target, bytes.NewReader([]byte("not a snapshot"))); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("want=%v，got=%v", ErrInvalidSnapshot, err)
+	}
+}
+
+// TestImport_InvalidHeader 快照头部的参数超出范围时，在加锁、写入redis之前返回ErrInvalidSnapshot
+func TestImport_InvalidHeader(t *testing.T) {
+	valid := snapshotHeader{Magic: snapshotMagic, Version: snapshotVersion, M: 1000, K: 7, Size: 125}
+	testCases := []struct {
+		name   string
+		header func(h *snapshotHeader)
+	}{
+		{name: "魔数错误", header: func(h *snapshotHeader) { h.Magic = [4]byte{'A', 'B', 'C', 'D'} }},
+		{name: "版本错误", header: func(h *snapshotHeader) { h.Version = snapshotVersion + 1 }},
+		{name: "M为0", header: func(h *snapshotHeader) { h.M = 0 }},
+		{name: "M超过bitmap的最大值", header: func(h *snapshotHeader) { h.M = maxBits + 1 }},
+		{name: "K为0", header: func(h *snapshotHeader) { h.K = 0 }},
+		{name: "K超过哈希函数个数的上限", header: func(h *snapshotHeader) { h.K = maxHashes + 1 }},
+		{name: "K非常大", header: func(h *snapshotHeader) { h.K = 1 << 40 }},
+		{name: "bitmap的字节数超过M", header: func(h *snapshotHeader) { h.Size = 126 }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := valid
+			tc.header(&header)
+			var buf bytes.Buffer
+			if err := binary.Write(&buf, binary.BigEndian, header); err != nil {
+				t.Fatal(err)
+			}
+			buf.Write(make([]byte, header.Size))
+			// cmd为nil，参数校验失败之前访问redis会panic
+			if _, err := Import(context.Background(), nil, "bloom:test", &buf); !errors.Is(err, ErrInvalidSnapshot) {
+				t.Fatalf("want=%v，got=%v", ErrInvalidSnapshot, err)
+			}
+		})
+	}
+}
+
+// TestBloomFilter_RebuildConcurrentAdd 重建期间其他实例添加的元素，替换之后不会丢失
+func TestBloomFilter_RebuildConcurrentAdd(t *testing.T) {
+	ctx := context.Background()
+	f := newRebuildFilter(t, 10_000)
+	// 其他实例，使用相同的key和参数
+	other := NewBloomFilterWithEstimates(f.cmd, f.bitmapKey, 10_000, 0.01)
+	polluted := rangeKeys("polluted", 1000)
+	if err := f.AddMany(ctx, polluted); err != nil {
+		t.Fatal(err)
+	}
+
+	source := rangeKeys("db", 3000)
+	added := rangeKeys("new", 300)
+	var wg sync.WaitGroup
+	keys := func(yield func(string, error) bool) {
+		for i, key := range source {
+			if i == 1000 {
+				// 重建进行到一半时，其他实例并发地添加元素
+				wg.Add(3)
+				for j := 0; j < 3; j++ {
+					go func(batch []string) {
+						defer wg.Done()
+						for _, key := range batch {
+							if err := other.Add(ctx, key); err != nil {
+								t.Error(err)
+							}
+						}
+					}(added[j*100 : (j+1)*100])
+				}
+				wg.Wait()
+			}
+			if !yield(key, nil) {
+				return
+			}
+		}
+	}
+	if err := f.Rebuild(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, f, source)
+	assertContains(t, f, added)
+	// 被污染的元素在重建后不存在了（误判率约1%）
+	res, _ := f.MightContainMany(ctx, polluted)
+	falsePositive := 0
+	for _, ok := range res {
+		if ok {
+			falsePositive++
+		}
+	}
+	if falsePositive > 50 {
+		t.Fatalf("重建后应该删除被污染的元素，got=%d", falsePositive)
+	}
+	// 重建完成后添加的元素不再写入影子key
+	if err := other.Add(ctx, "after"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := f.cmd.Exists(ctx, f.shadowKey(), f.lockKey()).Result(); n != 0 {
+		t.Fatal("重建完成后应该删除影子key和重建锁")
+	}
+}
+
+// TestBloomFilter_RebuildLock 同一时间只能有一个重建，重建失败时删除影子key并释放锁，原来的过滤器不受影响
+func TestBloomFilter_RebuildLock(t *testing.T) {
+	ctx := context.Background()
+	f := newRebuildFilter(t, 10_000)
+	if err := f.AddMany(ctx, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.Export(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	started, done := make(chan struct{}), make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- f.Rebuild(ctx, func(yield func(string, error) bool) {
+			yield("x", nil)
+			close(started)
+			<-done
+			yield("", errors.New("数据库查询失败"))
+		})
+	}()
+	<-started
+	if err := f.Rebuild(ctx, iter.Seq2[string, error](func(yield func(string, error) bool) {})); !errors.Is(err, ErrRebuilding) {
+		t.Fatalf("want=%v，got=%v", ErrRebuilding, err)
+	}
+	if _, err := Import(ctx, f.cmd, f.bitmapKey, bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrRebuilding) {
+		t.Fatalf("want=%v，got=%v", ErrRebuilding, err)
+	}
+	close(done)
+	if err := <-errCh; err == nil {
+		t.Fatal("迭代器返回error时，重建应该失败")
+	}
+	if n, _ := f.cmd.Exists(ctx, f.shadowKey(), f.lockKey()).Result(); n != 0 {
+		t.Fatal("重建失败后应该删除影子key和重建锁")
+	}
+	assertContains(t, f, []string{"a", "b"})
+	// 锁释放后可以重新重建
+	if err := f.Rebuild(ctx, func(yield func(string, error) bool) {
+		yield("c", nil)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, f, []string{"c"})
+}
@@ -0,0 +1,41 @@
+package bloomFilter
+
+import (
+	"context"
+	"math"
+)
+
+// Stats 布隆过滤器的统计信息
+type Stats struct {
+	Bits              uint64  // bitmap的大小（bit数）
+	BitsSet           uint64  // 值为1的bit数
+	FillRatio         float64 // 填充率，BitsSet / Bits
+	EstimatedCount    float64 // 估算的元素个数，-(m/k) * ln(1 - BitsSet/m)
+	FalsePositiveRate float64 // 当前估算的误判率，FillRatio^k
+}
+
+// Stats 使用BITCOUNT统计bitmap的填充率，估算元素个数和当前的误判率
+//
+//	BITCOUNT的时间复杂度是O(N)，2^32bit（512MB）的bitmap需要几百毫秒，会阻塞redis，不要频繁调用
+func (b *BloomFilter) Stats(ctx context.Context) (Stats, error) {
+	set, err := b.cmd.BitCount(ctx, b.bitmapKey, nil).Result()
+	if err != nil {
+		return Stats{}, err
+	}
+	return newStats(b.m, b.k, uint64(set)), nil
+}
+
+func newStats(m, k, set uint64) Stats {
+	ratio := float64(set) / float64(m)
+	estimated := math.Inf(1)
+	if ratio < 1 {
+		estimated = -float64(m) / float64(k) * math.Log(1-ratio)
+	}
+	return Stats{
+		Bits:              m,
+		BitsSet:           set,
+		FillRatio:         ratio,
+		EstimatedCount:    estimated,
+		FalsePositiveRate: math.Pow(ratio, float64(k)),
+	}
+}
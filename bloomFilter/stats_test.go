@@ -0,0 +1,149 @@
+package bloomFilter
+
+import (
+	"GoToolkit/loggerx"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"math/bits"
+	"testing"
+)
+
+func TestNewStats(t *testing.T) {
+	// 添加n个元素后，填充率约为1-e^(-kn/m)，估算的元素个数约为n
+	m, k := EstimateParameters(10000, 0.01)
+	f := NewMemoryFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		f.AddBytes([]byte{byte(i), byte(i >> 8), 'x'})
+	}
+	var set uint64
+	for i := range f.bits {
+		set += uint64(bits.OnesCount64(f.bits[i].Load()))
+	}
+	stats := newStats(m, k, set)
+	if stats.EstimatedCount < 9500 || stats.EstimatedCount > 10500 {
+		t.Fatalf("估算的元素个数误差过大，got=%.0f", stats.EstimatedCount)
+	}
+	if stats.FalsePositiveRate < 0.005 || stats.FalsePositiveRate > 0.015 {
+		t.Fatalf("估算的误判率误差过大，got=%.4f", stats.FalsePositiveRate)
+	}
+}
+
+// TestBloomFilter_Stats BITCOUNT统计的bit数和添加的元素一致，bitmap不存在时为0
+func TestBloomFilter_Stats(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	f := NewBloomFilterWithEstimates(client, newTestKey(t, client), 10000, 0.01)
+
+	stats, err := f.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Bits != f.m || stats.BitsSet != 0 || stats.FillRatio != 0 || stats.EstimatedCount != 0 {
+		t.Fatalf("bitmap不存在时，统计信息应该为0，got=%+v", stats)
+	}
+
+	keys := rangeKeys("user", 1000)
+	if err = f.AddMany(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[uint64]struct{})
+	for _, key := range keys {
+		for _, offset := range locations([]byte(key), f.k, f.m) {
+			want[offset] = struct{}{}
+		}
+	}
+	stats, err = f.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BitsSet != uint64(len(want)) {
+		t.Fatalf("值为1的bit数，want=%d，got=%d", len(want), stats.BitsSet)
+	}
+	if stats.EstimatedCount < 950 || stats.EstimatedCount > 1050 {
+		t.Fatalf("估算的元素个数误差过大，got=%.0f", stats.EstimatedCount)
+	}
+}
+
+// recordLogger 记录Error级别的日志
+type recordLogger struct {
+	errors []string
+}
+
+func (r *recordLogger) Debug(string, ...loggerx.Field) {}
+func (r *recordLogger) Info(string, ...loggerx.Field)  {}
+func (r *recordLogger) Warn(string, ...loggerx.Field)  {}
+func (r *recordLogger) Error(msg string, args ...loggerx.Field) {
+	r.errors = append(r.errors, msg)
+}
+
+// TestStatsCollector 每个过滤器上报5个指标，BITCOUNT失败的过滤器跳过并记录日志，不影响其他过滤器
+func TestStatsCollector(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	f := NewBloomFilterWithEstimates(client, newTestKey(t, client), 10000, 0.01)
+	if err := f.AddMany(ctx, rangeKeys("user", 100)); err != nil {
+		t.Fatal(err)
+	}
+	// key的类型不是string，BITCOUNT返回WRONGTYPE
+	broken := NewBloomFilterWithEstimates(client, newTestKey(t, client), 10000, 0.01)
+	if err := client.LPush(ctx, broken.bitmapKey, "x").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordLogger{}
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(newStatsCollector("test", "bloom", "1", logger, broken, f))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("一个过滤器采集失败，不应该影响整个采集，err=%v", err)
+	}
+	stats, _ := f.Stats(ctx)
+	want := map[string]float64{
+		"test_bloom_bloom_filter_bits":                float64(stats.Bits),
+		"test_bloom_bloom_filter_bits_set":            float64(stats.BitsSet),
+		"test_bloom_bloom_filter_fill_ratio":          stats.FillRatio,
+		"test_bloom_bloom_filter_estimated_count":     stats.EstimatedCount,
+		"test_bloom_bloom_filter_false_positive_rate": stats.FalsePositiveRate,
+	}
+	if len(families) != len(want) {
+		t.Fatalf("want %d个指标，got=%d", len(want), len(families))
+	}
+	for _, family := range families {
+		metrics := family.GetMetric()
+		if len(metrics) != 1 {
+			t.Fatalf("%s只应该上报可用的过滤器，got=%d", family.GetName(), len(metrics))
+		}
+		labels := map[string]string{}
+		for _, label := range metrics[0].GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["key"] != f.bitmapKey || labels["instance_id"] != "1" {
+			t.Fatalf("%s的标签错误，got=%v", family.GetName(), labels)
+		}
+		if got := metrics[0].GetGauge().GetValue(); got != want[family.GetName()] {
+			t.Fatalf("%s，want=%v，got=%v", family.GetName(), want[family.GetName()], got)
+		}
+	}
+	if len(logger.errors) != 1 {
+		t.Fatalf("采集失败应该记录日志，got=%v", logger.errors)
+	}
+}
+
+// TestNewStatsCollector_AlreadyRegistered 重复注册时返回error，而不是返回一个不会被采集的采集器
+func TestNewStatsCollector_AlreadyRegistered(t *testing.T) {
+	instanceId := uuid.NewString()
+	c, err := NewStatsCollector("test", "bloom", instanceId, &recordLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		prometheus.Unregister(c)
+	})
+	_, err = NewStatsCollector("test", "bloom", instanceId, &recordLogger{})
+	var are prometheus.AlreadyRegisteredError
+	if !errors.As(err, &are) || are.ExistingCollector != c {
+		t.Fatalf("want AlreadyRegisteredError，got=%v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package bloomFilter
+
+import (
+	"context"
+	"encoding/binary"
+)
+
+// Encoder 将元素编码为字节序列，相同的元素必须编码为相同的字节序列
+type Encoder[T any] func(val T) []byte
+
+// TypedBloomFilter 泛型布隆过滤器，使用Encoder将任意类型的元素编码为字节序列，底层可以是任意的Filter
+//
+//	比如用于防止缓存穿透，查询数据库之前先判断id是否存在：
+//
+//	filter := NewTypedBloomFilter(NewBloomFilterWithEstimates(cmd, "bloom:article", 1000_0000, 0.001), Int64Encoder)
+//	ok, err := filter.MightContain(ctx, articleId)
+type TypedBloomFilter[T any] struct {
+	filter Filter
+	encode Encoder[T]
+}
+
+func NewTypedBloomFilter[T any](filter Filter, encode Encoder[T]) *TypedBloomFilter[T] {
+	return &TypedBloomFilter[T]{
+		filter: filter,
+		encode: encode,
+	}
+}
+
+// Add 添加元素
+func (t *TypedBloomFilter[T]) Add(ctx context.Context, val T) error {
+	return t.filter.Add(ctx, string(t.encode(val)))
+}
+
+// MightContain 判断元素是否可能存在，返回false表示一定不存在
+func (t *TypedBloomFilter[T]) MightContain(ctx context.Context, val T) (bool, error) {
+	return t.filter.MightContain(ctx, string(t.encode(val)))
+}
+
+// AddMany 批量添加元素，一次网络往返
+func (t *TypedBloomFilter[T]) AddMany(ctx context.Context, vals []T) error {
+	return t.filter.AddMany(ctx, t.encodeMany(vals))
+}
+
+// MightContainMany 批量判断元素是否可能存在，一次网络往返，返回值和vals一一对应
+func (t *TypedBloomFilter[T]) MightContainMany(ctx context.Context, vals []T) ([]bool, error) {
+	return t.filter.MightContainMany(ctx, t.encodeMany(vals))
+}
+
+func (t *TypedBloomFilter[T]) encodeMany(vals []T) []string {
+	res := make([]string, len(vals))
+	for i, val := range vals {
+		res[i] = string(t.encode(val))
+	}
+	return res
+}
+
+// StringEncoder 字符串编码器
+func StringEncoder(val string) []byte {
+	return []byte(val)
+}
+
+// Int64Encoder int64编码器，使用大端序编码为8个字节
+func Int64Encoder(val int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(val))
+}
+
+// Uint64Encoder uint64编码器，使用大端序编码为8个字节
+func Uint64Encoder(val uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, val)
+}
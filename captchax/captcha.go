@@ -0,0 +1,121 @@
+package captchax
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCaptchaNotFound = errors.New("captchax: 验证码不存在或者已经过期")
+	ErrCaptchaMismatch = errors.New("captchax: 验证码错误")
+	ErrInvalidToken    = errors.New("captchax: 验证码凭证不合法或者已经过期")
+)
+
+// Captcha 展示给用户的验证码
+type Captcha struct {
+	Id      string `json:"id"`
+	Content string `json:"content"` // 题目或者数字的图片，data:image/png;base64,...
+}
+
+// Service 人机验证服务，验证码和凭证都保存在redis中
+//
+//	1.Issue 生成验证码，答案保存在redis中
+//	2.Verify 校验用户的答案，验证码只能校验一次，校验成功后返回一次性的凭证（token）
+//	3.ConsumeToken 发送短信验证码等敏感操作之前，消费凭证，凭证只能使用一次
+type Service struct {
+	cmd      redis.Cmdable
+	gen      Generator
+	ttl      time.Duration // 验证码的有效期
+	tokenTTL time.Duration // 凭证的有效期
+}
+
+// Option 人机验证服务的配置选项
+type Option func(*Service)
+
+// WithTTL 设置验证码的有效期，默认是2分钟
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.ttl = ttl
+	}
+}
+
+// WithTokenTTL 设置凭证的有效期，默认是5分钟
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.tokenTTL = ttl
+	}
+}
+
+func NewService(cmd redis.Cmdable, gen Generator, opts ...Option) *Service {
+	s := &Service{
+		cmd:      cmd,
+		gen:      gen,
+		ttl:      2 * time.Minute,
+		tokenTTL: 5 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Issue 生成验证码，答案保存在redis中
+func (s *Service) Issue(ctx context.Context) (Captcha, error) {
+	content, answer, err := s.gen.Generate()
+	if err != nil {
+		return Captcha{}, err
+	}
+	id := uuid.NewString()
+	if err = s.cmd.Set(ctx, s.key(id), answer, s.ttl).Err(); err != nil {
+		return Captcha{}, err
+	}
+	return Captcha{Id: id, Content: content}, nil
+}
+
+// Verify 校验用户的答案（不区分大小写），校验成功后返回一次性的凭证
+//
+//	无论答案是否正确，验证码都会被删除，避免暴力破解
+func (s *Service) Verify(ctx context.Context, id, answer string) (string, error) {
+	expected, err := s.cmd.GetDel(ctx, s.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrCaptchaNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(strings.TrimSpace(answer), expected) {
+		return "", ErrCaptchaMismatch
+	}
+	token := uuid.NewString()
+	if err = s.cmd.Set(ctx, s.tokenKey(token), 1, s.tokenTTL).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeToken 消费凭证，凭证只能使用一次
+func (s *Service) ConsumeToken(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	n, err := s.cmd.Del(ctx, s.tokenKey(token)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *Service) key(id string) string {
+	return "captcha:" + id
+}
+
+func (s *Service) tokenKey(token string) string {
+	return "captcha_token:" + token
+}
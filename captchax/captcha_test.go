@@ -0,0 +1,218 @@
+package captchax
+
+import (
+	"GoToolkit/loggerx"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRedisClient 连接本地的redis，redis不可用时跳过测试
+func newRedisClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis不可用，跳过测试，err=%v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+// decodeImage 校验内容是data:image/png;base64,...格式的PNG图片
+func decodeImage(t *testing.T, content string) image.Image {
+	t.Helper()
+	data, ok := strings.CutPrefix(content, "data:image/png;base64,")
+	if !ok {
+		t.Fatalf("应该返回data:image/png;base64,...格式的内容，got=%.32s", content)
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("应该是合法的PNG图片，err=%v", err)
+	}
+	return img
+}
+
+func TestArithmeticGenerator(t *testing.T) {
+	gen := NewArithmeticGenerator(10)
+	for i := 0; i < 100; i++ {
+		question, answer, err := gen.question()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var x, y int
+		var op rune
+		if _, err = fmt.Sscanf(question, "%d%c%d=?", &x, &op, &y); err != nil {
+			t.Fatalf("question=%s，err=%v", question, err)
+		}
+		want := x + y
+		if op == '-' {
+			want = x - y
+		}
+		if answer != strconv.Itoa(want) || want < 0 {
+			t.Fatalf("question=%s，answer=%s", question, answer)
+		}
+	}
+
+	// 题目绘制成图片，不能返回纯文本的题目
+	content, answer, err := gen.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(content, "=?") || answer == "" {
+		t.Fatalf("content=%.32s，answer=%s", content, answer)
+	}
+	// 最长的题目是"10+10=?"，7个字符
+	if width := decodeImage(t, content).Bounds().Dx(); width > (7*8+3)*gen.scale {
+		t.Fatalf("图片的宽度超过最长的题目，got=%d", width)
+	}
+}
+
+func TestImageGenerator(t *testing.T) {
+	gen := NewImageGenerator(4)
+	content, answer, err := gen.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer) != 4 {
+		t.Fatalf("答案应该是4位数字，got=%s", answer)
+	}
+	if width := decodeImage(t, content).Bounds().Dx(); width != (4*8+3)*gen.scale {
+		t.Fatalf("图片的宽度和位数不一致，got=%d", width)
+	}
+}
+
+func TestRender_UnsupportedChar(t *testing.T) {
+	if _, err := render("1+x=?", 1); err == nil {
+		t.Fatal("没有字形的字符，应该返回error")
+	}
+}
+
+// TestGenerator_InvalidArgs 参数不合法时，创建的时候panic，而不是在生成验证码时panic或者生成空的答案
+func TestGenerator_InvalidArgs(t *testing.T) {
+	testCases := []struct {
+		name string
+		new  func()
+	}{
+		{name: "操作数的最大值为0", new: func() { NewArithmeticGenerator(0) }},
+		{name: "操作数的最大值为负数", new: func() { NewArithmeticGenerator(-1) }},
+		{name: "位数为0", new: func() { NewImageGenerator(0) }},
+		{name: "位数为负数", new: func() { NewImageGenerator(-1) }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("参数不合法，应该panic")
+				}
+			}()
+			tc.new()
+		})
+	}
+
+	// 合法的边界值
+	_, answer, err := NewArithmeticGenerator(1).Generate()
+	if err != nil || (answer != "0" && answer != "1" && answer != "2") {
+		t.Fatalf("answer=%s，err=%v", answer, err)
+	}
+	if _, answer, err = NewImageGenerator(1).Generate(); err != nil || len(answer) != 1 {
+		t.Fatalf("answer=%s，err=%v", answer, err)
+	}
+}
+
+// constGenerator 固定答案的验证码
+type constGenerator string
+
+func (c constGenerator) Generate() (string, string, error) {
+	return "question", string(c), nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...loggerx.Field) {}
+func (nopLogger) Info(string, ...loggerx.Field)  {}
+func (nopLogger) Warn(string, ...loggerx.Field)  {}
+func (nopLogger) Error(string, ...loggerx.Field) {}
+
+func TestService(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	svc := NewService(client, constGenerator("AbCd"))
+
+	captcha, err := svc.Issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 答案错误，验证码失效，不能再次校验
+	if _, err = svc.Verify(ctx, captcha.Id, "wrong"); !errors.Is(err, ErrCaptchaMismatch) {
+		t.Fatalf("want=%v，got=%v", ErrCaptchaMismatch, err)
+	}
+	if _, err = svc.Verify(ctx, captcha.Id, "abcd"); !errors.Is(err, ErrCaptchaNotFound) {
+		t.Fatalf("want=%v，got=%v", ErrCaptchaNotFound, err)
+	}
+
+	// 答案不区分大小写，凭证只能使用一次
+	captcha, _ = svc.Issue(ctx)
+	token, err := svc.Verify(ctx, captcha.Id, "abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.ConsumeToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.ConsumeToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("want=%v，got=%v", ErrInvalidToken, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	client := newRedisClient(t)
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	svc := NewService(client, constGenerator("42"))
+	NewHandler(svc, nopLogger{}).RegisterRoutes(&server.RouterGroup, "/captcha")
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/captcha", nil))
+	var issued struct {
+		Data Captcha
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &issued); err != nil || issued.Data.Id == "" {
+		t.Fatalf("生成验证码失败，body=%s", recorder.Body.String())
+	}
+
+	body, _ := json.Marshal(VerifyReq{Id: issued.Data.Id, Answer: "42"})
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/captcha/verify", bytes.NewReader(body)))
+	var verified struct {
+		Data string
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &verified); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("校验验证码失败，body=%s", recorder.Body.String())
+	}
+	if err := svc.ConsumeToken(context.Background(), verified.Data); err != nil {
+		t.Fatalf("应该返回有效的凭证，err=%v", err)
+	}
+}
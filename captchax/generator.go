@@ -0,0 +1,210 @@
+package captchax
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/big"
+	"strconv"
+)
+
+// Generator 生成验证码，返回展示给用户的内容和答案
+type Generator interface {
+	Generate() (content, answer string, err error)
+}
+
+// ArithmeticGenerator 算术验证码，把题目比如"3+5=?"绘制成PNG图片，答案是"8"
+//
+//	返回data:image/png;base64,...格式的内容，和ImageGenerator一样可以直接作为img的src，
+//	题目不能使用纯文本返回，否则脚本直接解析文本就可以算出答案
+//	只生成加法和减法，减法的结果不会是负数
+type ArithmeticGenerator struct {
+	maxOperand int // 操作数的最大值
+	scale      int // 放大倍数，字形是5x7的点阵
+}
+
+// NewArithmeticGenerator maxOperand必须大于0，否则没有可以选择的操作数，或者答案是固定的
+func NewArithmeticGenerator(maxOperand int) *ArithmeticGenerator {
+	if maxOperand <= 0 {
+		panic("captchax: 操作数的最大值必须大于0")
+	}
+	return &ArithmeticGenerator{
+		maxOperand: maxOperand,
+		scale:      4,
+	}
+}
+
+func (a *ArithmeticGenerator) Generate() (string, string, error) {
+	question, answer, err := a.question()
+	if err != nil {
+		return "", "", err
+	}
+	content, err := render(question, a.scale)
+	if err != nil {
+		return "", "", err
+	}
+	return content, answer, nil
+}
+
+// question 生成题目和答案，比如"3+5=?"和"8"
+func (a *ArithmeticGenerator) question() (string, string, error) {
+	x, err := randInt(a.maxOperand + 1)
+	if err != nil {
+		return "", "", err
+	}
+	y, err := randInt(a.maxOperand + 1)
+	if err != nil {
+		return "", "", err
+	}
+	op, err := randInt(2)
+	if err != nil {
+		return "", "", err
+	}
+	if op == 0 {
+		return fmt.Sprintf("%d+%d=?", x, y), strconv.Itoa(x + y), nil
+	}
+	x, y = max(x, y), min(x, y)
+	return fmt.Sprintf("%d-%d=?", x, y), strconv.Itoa(x - y), nil
+}
+
+// ImageGenerator 图片验证码，生成length位数字的PNG图片，返回data:image/png;base64,...格式的内容，可以直接作为img的src
+type ImageGenerator struct {
+	length int // 数字的位数
+	scale  int // 放大倍数，字形是5x7的点阵
+}
+
+// NewImageGenerator length必须大于0，否则答案是空字符串，任何人都可以通过验证
+func NewImageGenerator(length int) *ImageGenerator {
+	if length <= 0 {
+		panic("captchax: 验证码的位数必须大于0")
+	}
+	return &ImageGenerator{
+		length: length,
+		scale:  4,
+	}
+}
+
+func (g *ImageGenerator) Generate() (string, string, error) {
+	answer := make([]byte, g.length)
+	for i := range answer {
+		digit, err := randInt(10)
+		if err != nil {
+			return "", "", err
+		}
+		answer[i] = byte('0' + digit)
+	}
+	content, err := render(string(answer), g.scale)
+	if err != nil {
+		return "", "", err
+	}
+	return content, string(answer), nil
+}
+
+// render 把text绘制成PNG图片，返回data:image/png;base64,...格式的内容，text只能包含glyphs中的字符
+//
+//	每个字符随机偏移、随机颜色，并添加干扰点，增加OCR识别的难度
+func render(text string, scale int) (string, error) {
+	const glyphWidth, glyphHeight, padding = 5, 7, 3
+	width := (len(text)*(glyphWidth+padding) + padding) * scale
+	height := (glyphHeight + 2*padding) * scale
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	// 白色背景
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for i := 0; i < len(text); i++ {
+		glyph, ok := glyphs[text[i]]
+		if !ok {
+			return "", fmt.Errorf("captchax: 不支持绘制字符%q", text[i])
+		}
+		// 随机的垂直偏移和颜色
+		dy, err := randInt(2*padding - 1)
+		if err != nil {
+			return "", err
+		}
+		c, err := randColor()
+		if err != nil {
+			return "", err
+		}
+		x0 := (padding + i*(glyphWidth+padding)) * scale
+		y0 := (dy + 1) * scale
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				fill(img, x0+col*scale, y0+row*scale, scale, c)
+			}
+		}
+	}
+	// 干扰点
+	for i := 0; i < width*height/20; i++ {
+		x, err := randInt(width)
+		if err != nil {
+			return "", err
+		}
+		y, err := randInt(height)
+		if err != nil {
+			return "", err
+		}
+		c, err := randColor()
+		if err != nil {
+			return "", err
+		}
+		img.Set(x, y, c)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// fill 填充一个scale*scale的方块
+func fill(img *image.RGBA, x, y, scale int, c color.Color) {
+	for i := 0; i < scale; i++ {
+		for j := 0; j < scale; j++ {
+			img.Set(x+i, y+j, c)
+		}
+	}
+}
+
+// glyphs 数字和算术符号的5x7点阵字形，每一行使用低5位表示
+var glyphs = map[byte][7]uint8{
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'+': {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'=': {0x00, 0x00, 0x1f, 0x00, 0x1f, 0x00, 0x00},
+	'?': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+}
+
+// randInt 使用crypto/rand生成[0, n)的随机数
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// randColor 随机的深色，和白色背景有足够的对比度
+func randColor() (color.Color, error) {
+	var rgb [3]byte
+	if _, err := rand.Read(rgb[:]); err != nil {
+		return nil, err
+	}
+	return color.RGBA{R: rgb[0] / 2, G: rgb[1] / 2, B: rgb[2] / 2, A: 0xff}, nil
+}
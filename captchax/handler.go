@@ -0,0 +1,87 @@
+package captchax
+
+import (
+	"GoToolkit/ginx/middleware"
+	"GoToolkit/loggerx"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Handler 人机验证的HTTP接口
+//
+//	server.GET("/captcha", handler.Issue)
+//	server.POST("/captcha/verify", handler.Verify)
+type Handler struct {
+	svc    *Service
+	logger loggerx.Logger
+}
+
+func NewHandler(svc *Service, logger loggerx.Logger) *Handler {
+	return &Handler{
+		svc:    svc,
+		logger: logger,
+	}
+}
+
+// RegisterRoutes 注册路由，GET prefix 生成验证码，POST prefix/verify 校验验证码
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup, prefix string) {
+	group.GET(prefix, h.Issue)
+	group.POST(prefix+"/verify", h.Verify)
+}
+
+// Issue 生成验证码
+func (h *Handler) Issue(ctx *gin.Context) {
+	captcha, err := h.svc.Issue(ctx.Request.Context())
+	if err != nil {
+		h.logger.Error("生成验证码失败", loggerx.Error(err))
+		ctx.JSON(http.StatusInternalServerError, middleware.Result[string]{
+			Code: http.StatusInternalServerError,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, middleware.Result[Captcha]{
+		Code: http.StatusOK,
+		Msg:  "success",
+		Data: captcha,
+	})
+}
+
+// VerifyReq 校验验证码的请求
+type VerifyReq struct {
+	Id     string `json:"id" binding:"required"`
+	Answer string `json:"answer" binding:"required"`
+}
+
+// Verify 校验验证码，校验成功后返回凭证，发送短信验证码时携带凭证
+func (h *Handler) Verify(ctx *gin.Context) {
+	var req VerifyReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, middleware.Result[string]{
+			Code: http.StatusBadRequest,
+			Msg:  "参数错误",
+		})
+		return
+	}
+	token, err := h.svc.Verify(ctx.Request.Context(), req.Id, req.Answer)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, middleware.Result[string]{
+			Code: http.StatusOK,
+			Msg:  "success",
+			Data: token,
+		})
+	case errors.Is(err, ErrCaptchaNotFound), errors.Is(err, ErrCaptchaMismatch):
+		ctx.JSON(http.StatusBadRequest, middleware.Result[string]{
+			Code: http.StatusBadRequest,
+			Msg:  "验证码错误，请重新获取",
+		})
+	default:
+		h.logger.Error("校验验证码失败", loggerx.Error(err))
+		ctx.JSON(http.StatusInternalServerError, middleware.Result[string]{
+			Code: http.StatusInternalServerError,
+			Msg:  "系统错误",
+		})
+	}
+}
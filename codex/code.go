@@ -0,0 +1,313 @@
+package codex
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/smsx"
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"maps"
+	"math/big"
+)
+
+var (
+	//go:embed set_code.lua
+	luaSetCode string
+	//go:embed verify_code.lua
+	luaVerifyCode string
+
+	// 使用EVALSHA执行，redis中没有缓存脚本（NOSCRIPT）时，自动使用EVAL执行并缓存
+	setCodeScript    = redis.NewScript(luaSetCode)
+	verifyCodeScript = redis.NewScript(luaVerifyCode)
+)
+
+var (
+	ErrSendTooFrequent    = errors.New("codex: 验证码发送太频繁")
+	ErrDailyQuotaExceeded = errors.New("codex: 今日发送验证码的次数超过上限")
+	ErrTooManyAttempts    = errors.New("codex: 验证码输入错误的次数太多，请重新获取验证码")
+	ErrCodeMismatch       = errors.New("codex: 验证码错误")
+	ErrCodeExpired        = errors.New("codex: 验证码不存在或者已经过期，请重新获取验证码")
+	ErrCodeSystem         = errors.New("codex: 验证码系统错误")
+	ErrNoEmailSender      = errors.New("codex: 没有设置邮件服务")
+	ErrCaptchaRequired    = errors.New("codex: 需要先通过人机验证")
+	ErrIPLimited          = errors.New("codex: 当前IP发送验证码太频繁")
+	ErrClientIPRequired   = errors.New("codex: 设置了IP限流，发送验证码时需要携带客户端的IP")
+)
+
+// CodeService 验证码服务，封装了set_code.lua和verify_code.lua，支持短信和邮件两个渠道
+//
+//	发送频率、发送次数、有效期和验证次数由业务场景（biz）对应的Policy决定，没有配置的业务场景使用DefaultPolicy
+//	不同的业务（biz，比如login、payment）、不同的渠道使用不同的key，互不影响
+type CodeService struct {
+	cmd      redis.Cmdable
+	policies map[string]Policy
+	fallback Policy // 没有配置策略的业务场景使用的策略
+
+	sender smsx.Service // 不为nil时，Send生成验证码之后直接发送短信
+	tplId  string       // 短信模板id，模板参数是验证码
+
+	emailSender EmailSender // 邮件服务，SendEmail生成验证码之后发送邮件
+
+	captcha   CaptchaVerifier // 不为nil时，发送验证码之前必须通过人机验证
+	ipLimiter limitx.Limiter  // 不为nil时，限制同一个IP发送验证码的频率
+}
+
+// CaptchaVerifier 人机验证，captchax.Service实现了这个接口
+type CaptchaVerifier interface {
+	// ConsumeToken 消费人机验证通过后得到的凭证，凭证只能使用一次
+	ConsumeToken(ctx context.Context, token string) error
+}
+
+// SendOption 发送验证码的参数
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	captchaToken string
+	clientIP     string
+}
+
+// WithCaptchaToken 人机验证通过后得到的凭证，设置了WithCaptcha时必须携带
+func WithCaptchaToken(token string) SendOption {
+	return func(o *sendOptions) {
+		o.captchaToken = token
+	}
+}
+
+// WithClientIP 客户端的IP，设置了WithIPLimiter时用来限制同一个IP发送验证码的频率
+func WithClientIP(ip string) SendOption {
+	return func(o *sendOptions) {
+		o.clientIP = ip
+	}
+}
+
+// CodeOption 验证码服务的配置选项
+type CodeOption func(*CodeService)
+
+// WithPolicy 设置业务场景的策略，覆盖DefaultPolicies中的策略，策略不合法时panic
+func WithPolicy(biz string, policy Policy) CodeOption {
+	if err := policy.validate(); err != nil {
+		panic(err)
+	}
+	return func(s *CodeService) {
+		s.policies[biz] = policy
+	}
+}
+
+// WithDefaultPolicy 设置没有配置策略的业务场景使用的策略，默认是DefaultPolicy，策略不合法时panic
+func WithDefaultPolicy(policy Policy) CodeOption {
+	if err := policy.validate(); err != nil {
+		panic(err)
+	}
+	return func(s *CodeService) {
+		s.fallback = policy
+	}
+}
+
+// WithSender 设置短信服务，Send生成验证码之后使用tplId模板发送短信，模板的参数是验证码
+func WithSender(sender smsx.Service, tplId string) CodeOption {
+	return func(s *CodeService) {
+		s.sender = sender
+		s.tplId = tplId
+	}
+}
+
+// WithEmailSender 设置邮件服务，使用SendEmail、VerifyEmail之前必须设置
+func WithEmailSender(sender EmailSender) CodeOption {
+	return func(s *CodeService) {
+		s.emailSender = sender
+	}
+}
+
+// WithCaptcha 发送验证码之前必须通过人机验证，防止脚本轮换手机号消耗短信资源
+func WithCaptcha(verifier CaptchaVerifier) CodeOption {
+	return func(s *CodeService) {
+		s.captcha = verifier
+	}
+}
+
+// WithIPLimiter 限制同一个IP发送验证码的频率，限流对象是code:send:ip:{ip}，比如：
+//
+//	WithIPLimiter(limitx.NewRedisSlidingWindowLimiter(cmd, time.Hour, 20))
+//
+//	发送时必须使用WithClientIP携带客户端的IP，没有携带时返回ErrClientIPRequired，
+//	否则忘记携带IP的调用方会绕过IP限流
+func WithIPLimiter(limiter limitx.Limiter) CodeOption {
+	return func(s *CodeService) {
+		s.ipLimiter = limiter
+	}
+}
+
+// NewCodeService 创建验证码服务，DefaultPolicies、DefaultPolicy被修改成不合法的策略时panic
+func NewCodeService(cmd redis.Cmdable, opts ...CodeOption) *CodeService {
+	s := &CodeService{
+		cmd:      cmd,
+		policies: maps.Clone(DefaultPolicies),
+		fallback: DefaultPolicy,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for biz, policy := range s.policies {
+		if err := policy.validate(); err != nil {
+			panic(fmt.Errorf("%w，biz=%s", err, biz))
+		}
+	}
+	if err := s.fallback.validate(); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Send 生成短信验证码并保存到redis中，返回生成的验证码
+//
+//	设置了WithSender时，保存成功之后发送短信，发送失败时删除验证码，用户可以立即重新获取
+//	（发送次数已经扣减，不会退还）；没有设置时，调用方负责把验证码发送给用户
+func (s *CodeService) Send(ctx context.Context, biz, phone string, opts ...SendOption) (string, error) {
+	return s.send(ctx, biz, s.key(biz, phone), opts, func(code string) error {
+		if s.sender == nil {
+			return nil
+		}
+		return s.sender.Send(ctx, s.tplId, []string{code}, phone)
+	})
+}
+
+// Verify 校验用户输入的短信验证码，验证成功返回nil，验证成功后验证码失效
+//
+//	没有发送过验证码、验证码已经过期或者已经验证成功过时返回ErrCodeExpired，输入错误时返回ErrCodeMismatch
+func (s *CodeService) Verify(ctx context.Context, biz, phone, input string) error {
+	return s.verify(ctx, biz, s.key(biz, phone), input)
+}
+
+// SendEmail 生成邮件验证码并发送邮件，和短信验证码使用相同的策略，但是分开计数
+func (s *CodeService) SendEmail(ctx context.Context, biz, email string, opts ...SendOption) error {
+	if s.emailSender == nil {
+		return ErrNoEmailSender
+	}
+	_, err := s.send(ctx, biz, s.emailKey(biz, email), opts, func(code string) error {
+		return s.emailSender.SendCode(ctx, biz, email, code)
+	})
+	return err
+}
+
+// VerifyEmail 校验用户输入的邮件验证码，验证成功返回nil
+func (s *CodeService) VerifyEmail(ctx context.Context, biz, email, input string) error {
+	return s.verify(ctx, biz, s.emailKey(biz, email), input)
+}
+
+// send 检查IP限流和人机验证，生成验证码并保存到redis中，保存成功之后调用deliver发送验证码
+func (s *CodeService) send(ctx context.Context, biz, key string, opts []SendOption,
+	deliver func(code string) error) (string, error) {
+	if err := s.check(ctx, opts); err != nil {
+		return "", err
+	}
+	policy := s.policy(biz)
+	// 策略不合法时，lua脚本会设置错误的过期时间，不发送验证码
+	if err := policy.validate(); err != nil {
+		return "", err
+	}
+	code, err := generate(policy.CodeLength)
+	if err != nil {
+		return "", err
+	}
+	res, err := setCodeScript.Run(ctx, s.cmd, []string{key}, code,
+		int64(policy.CodeTTL.Seconds()),
+		int64(policy.ResendInterval.Seconds()),
+		policy.DailyLimit,
+		int64(policy.QuotaWindow.Seconds())).Int()
+	if err != nil {
+		return "", err
+	}
+	switch res {
+	case 0:
+		if err = deliver(code); err != nil {
+			// 发送失败，删除验证码，用户可以立即重新获取
+			s.cmd.Del(ctx, key)
+			return "", fmt.Errorf("发送验证码失败，%w", err)
+		}
+		return code, nil
+	case -2:
+		return "", ErrSendTooFrequent
+	case -3:
+		return "", ErrDailyQuotaExceeded
+	default:
+		// -1，验证码存在，但是没有过期时间
+		return "", ErrCodeSystem
+	}
+}
+
+func (s *CodeService) verify(ctx context.Context, biz, key, input string) error {
+	res, err := verifyCodeScript.Run(ctx, s.cmd, []string{key}, input,
+		s.policy(biz).MaxAttempts).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		return nil
+	case -1:
+		return ErrCodeMismatch
+	case -2:
+		return ErrTooManyAttempts
+	case -3:
+		return ErrCodeExpired
+	default:
+		return ErrCodeSystem
+	}
+}
+
+// check 先检查IP限流，再消费人机验证的凭证，被限流时不会消耗凭证
+func (s *CodeService) check(ctx context.Context, opts []SendOption) error {
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if s.ipLimiter != nil {
+		if o.clientIP == "" {
+			return ErrClientIPRequired
+		}
+		limited, err := s.ipLimiter.Limit(ctx, "code:send:ip:"+o.clientIP)
+		if err != nil {
+			return err
+		}
+		if limited {
+			return ErrIPLimited
+		}
+	}
+	if s.captcha != nil {
+		if o.captchaToken == "" {
+			return ErrCaptchaRequired
+		}
+		if err := s.captcha.ConsumeToken(ctx, o.captchaToken); err != nil {
+			return fmt.Errorf("%w，%w", ErrCaptchaRequired, err)
+		}
+	}
+	return nil
+}
+
+// policy 获取业务场景的策略
+func (s *CodeService) policy(biz string) Policy {
+	if policy, ok := s.policies[biz]; ok {
+		return policy
+	}
+	return s.fallback
+}
+
+func (s *CodeService) key(biz, phone string) string {
+	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
+}
+
+func (s *CodeService) emailKey(biz, email string) string {
+	return fmt.Sprintf("email_code:%s:%s", biz, email)
+}
+
+// generate 使用crypto/rand生成length位的数字验证码，不足length位时前面补0
+func generate(length int) (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
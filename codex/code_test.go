@@ -0,0 +1,326 @@
+package codex
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/smsx"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+// newRedisClient 连接本地的redis，redis不可用时跳过测试
+func newRedisClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis不可用，跳过测试，err=%v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+func TestCodeService(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	svc := NewCodeService(client)
+	const biz, phone = "test", "13800138000"
+	key := svc.key(biz, phone)
+	t.Cleanup(func() {
+		client.Del(ctx, key, key+":send:count", key+":verify:count")
+	})
+	client.Del(ctx, key, key+":send:count", key+":verify:count")
+
+	code, err := svc.Send(ctx, biz, phone)
+	if err != nil || len(code) != 6 {
+		t.Fatalf("发送验证码失败，code=%s，err=%v", code, err)
+	}
+	// 1分钟内重复发送
+	if _, err = svc.Send(ctx, biz, phone); !errors.Is(err, ErrSendTooFrequent) {
+		t.Fatalf("want=%v，got=%v", ErrSendTooFrequent, err)
+	}
+	if err = svc.Verify(ctx, biz, phone, "wrong"); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+	}
+	if err = svc.Verify(ctx, biz, phone, code); err != nil {
+		t.Fatalf("验证码正确，应该验证成功，err=%v", err)
+	}
+	// 验证成功后，验证码失效
+	if err = svc.Verify(ctx, biz, phone, code); !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("验证成功后，验证码应该失效，want=%v，got=%v", ErrCodeExpired, err)
+	}
+
+	// 连续输错，超过最大次数
+	client.Del(ctx, key, key+":verify:count")
+	code, err = svc.Send(ctx, biz, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = svc.Verify(ctx, biz, phone, "wrong"); !errors.Is(err, ErrCodeMismatch) {
+			t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+		}
+	}
+	if err = svc.Verify(ctx, biz, phone, code); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("want=%v，got=%v", ErrTooManyAttempts, err)
+	}
+
+	// 24小时内最多发送5次
+	for i := 0; i < 3; i++ {
+		client.Del(ctx, key)
+		if _, err = svc.Send(ctx, biz, phone); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Del(ctx, key)
+	if _, err = svc.Send(ctx, biz, phone); !errors.Is(err, ErrDailyQuotaExceeded) {
+		t.Fatalf("want=%v，got=%v", ErrDailyQuotaExceeded, err)
+	}
+}
+
+// TestCodeService_Expired 验证码不存在或者已经过期时返回ErrCodeExpired，和输入错误区分开
+func TestCodeService_Expired(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	svc := NewCodeService(client)
+	const biz, phone = "test_expired", "13800138000"
+	key := svc.key(biz, phone)
+	t.Cleanup(func() {
+		client.Del(ctx, key, key+":send:count", key+":verify:count")
+	})
+	client.Del(ctx, key, key+":send:count", key+":verify:count")
+
+	// 没有发送过验证码
+	if err := svc.Verify(ctx, biz, phone, "123456"); !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("want=%v，got=%v", ErrCodeExpired, err)
+	}
+	code, err := svc.Send(ctx, biz, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.Verify(ctx, biz, phone, "wrong"); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+	}
+	// 验证码过期，redis删除了key
+	client.Del(ctx, key)
+	if err = svc.Verify(ctx, biz, phone, code); !errors.Is(err, ErrCodeExpired) {
+		t.Fatalf("want=%v，got=%v", ErrCodeExpired, err)
+	}
+}
+
+func TestCodeService_Policy(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const biz, phone = "test_policy", "13800138000"
+	svc := NewCodeService(client, WithPolicy(biz, Policy{
+		CodeLength:     4,
+		CodeTTL:        time.Minute,
+		ResendInterval: 0,
+		DailyLimit:     2,
+		QuotaWindow:    time.Hour,
+		MaxAttempts:    1,
+	}))
+	key := svc.key(biz, phone)
+	t.Cleanup(func() {
+		client.Del(ctx, key, key+":send:count", key+":verify:count")
+	})
+	client.Del(ctx, key, key+":send:count", key+":verify:count")
+
+	code, err := svc.Send(ctx, biz, phone)
+	if err != nil || len(code) != 4 {
+		t.Fatalf("发送验证码失败，code=%s，err=%v", code, err)
+	}
+	if ttl := client.TTL(ctx, key+":send:count").Val(); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("发送次数的过期时间应该是统计周期，got=%s", ttl)
+	}
+	if err = svc.Verify(ctx, biz, phone, "wrong"); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+	}
+	// 验证次数的key和验证码同时过期
+	if ttl := client.TTL(ctx, key+":verify:count").Val(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("验证次数应该有过期时间，got=%s", ttl)
+	}
+	// 没有发送间隔，可以立即重新发送，新的验证码重新计算验证次数
+	code, err = svc.Send(ctx, biz, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = svc.Verify(ctx, biz, phone, code); err != nil {
+		t.Fatalf("验证码正确，应该验证成功，err=%v", err)
+	}
+	if _, err = svc.Send(ctx, biz, phone); !errors.Is(err, ErrDailyQuotaExceeded) {
+		t.Fatalf("want=%v，got=%v", ErrDailyQuotaExceeded, err)
+	}
+}
+
+// TestPolicy_Invalid 不合法的策略在创建服务时panic，发送时返回ErrInvalidPolicy，不会执行lua脚本
+func TestPolicy_Invalid(t *testing.T) {
+	valid := DefaultPolicy
+	testCases := []struct {
+		name   string
+		policy func(p *Policy)
+	}{
+		{name: "验证码的位数为0", policy: func(p *Policy) { p.CodeLength = 0 }},
+		{name: "有效期为0", policy: func(p *Policy) { p.CodeTTL = 0 }},
+		{name: "有效期不足1秒", policy: func(p *Policy) { p.CodeTTL = 500 * time.Millisecond }},
+		{name: "发送间隔为负数", policy: func(p *Policy) { p.ResendInterval = -time.Minute }},
+		{name: "发送间隔不足1秒", policy: func(p *Policy) { p.ResendInterval = time.Millisecond }},
+		{name: "发送次数为0", policy: func(p *Policy) { p.DailyLimit = 0 }},
+		{name: "统计周期为负数", policy: func(p *Policy) { p.QuotaWindow = -time.Hour }},
+		{name: "统计周期不足1秒", policy: func(p *Policy) { p.QuotaWindow = 999 * time.Millisecond }},
+		{name: "验证次数为0", policy: func(p *Policy) { p.MaxAttempts = 0 }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := valid
+			tc.policy(&policy)
+			options := map[string]func(){
+				"WithPolicy":        func() { WithPolicy("test", policy) },
+				"WithDefaultPolicy": func() { WithDefaultPolicy(policy) },
+			}
+			for name, option := range options {
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Fatalf("%s，策略不合法，应该panic", name)
+						}
+					}()
+					option()
+				}()
+			}
+			// cmd为nil，执行lua脚本会panic
+			svc := &CodeService{fallback: policy}
+			if _, err := svc.Send(context.Background(), "test", "13800138000"); !errors.Is(err, ErrInvalidPolicy) {
+				t.Fatalf("want=%v，got=%v", ErrInvalidPolicy, err)
+			}
+		})
+	}
+	// 没有发送间隔是合法的
+	WithPolicy("test", Policy{CodeLength: 4, CodeTTL: time.Second, DailyLimit: 1, QuotaWindow: time.Second, MaxAttempts: 1})
+}
+
+func TestCodeService_Sender(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const biz, phone = "test_sender", "13800138000"
+	sender := smsx.NewFakeService()
+	svc := NewCodeService(client, WithSender(sender, "tpl_code"))
+	key := svc.key(biz, phone)
+	t.Cleanup(func() {
+		client.Del(ctx, key, key+":send:count", key+":verify:count")
+	})
+	client.Del(ctx, key, key+":send:count", key+":verify:count")
+
+	code, err := svc.Send(ctx, biz, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sms, ok := sender.Last(phone)
+	if !ok || sms.TplId != "tpl_code" || sms.Args[0] != code {
+		t.Fatalf("应该使用短信服务发送验证码，got=%+v", sms)
+	}
+	// 发送失败时删除验证码，可以立即重新获取
+	client.Del(ctx, key)
+	sender.SetError(errors.New("服务商故障"))
+	if _, err = svc.Send(ctx, biz, phone); err == nil {
+		t.Fatal("短信发送失败，应该返回错误")
+	}
+	sender.SetError(nil)
+	if _, err = svc.Send(ctx, biz, phone); err != nil {
+		t.Fatalf("短信发送失败后，应该可以立即重新获取，err=%v", err)
+	}
+}
+
+// fakeEmailSender 记录最后一次发送的验证码
+type fakeEmailSender struct {
+	codes map[string]string
+}
+
+func (f *fakeEmailSender) SendCode(ctx context.Context, biz, email, code string) error {
+	f.codes[email] = code
+	return nil
+}
+
+func TestCodeService_Email(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const biz, email = "test_email", "alice@example.com"
+	sender := &fakeEmailSender{codes: make(map[string]string)}
+	svc := NewCodeService(client, WithEmailSender(sender))
+	key := svc.emailKey(biz, email)
+	t.Cleanup(func() {
+		client.Del(ctx, key, key+":send:count", key+":verify:count")
+	})
+	client.Del(ctx, key, key+":send:count", key+":verify:count")
+
+	if err := svc.SendEmail(ctx, biz, email); err != nil {
+		t.Fatal(err)
+	}
+	// 邮件验证码和短信验证码使用相同的发送频率限制
+	if err := svc.SendEmail(ctx, biz, email); !errors.Is(err, ErrSendTooFrequent) {
+		t.Fatalf("want=%v，got=%v", ErrSendTooFrequent, err)
+	}
+	if err := svc.VerifyEmail(ctx, biz, email, "wrong"); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+	}
+	if err := svc.VerifyEmail(ctx, biz, email, sender.codes[email]); err != nil {
+		t.Fatalf("验证码正确，应该验证成功，err=%v", err)
+	}
+	if err := NewCodeService(client).SendEmail(ctx, biz, email); !errors.Is(err, ErrNoEmailSender) {
+		t.Fatalf("want=%v，got=%v", ErrNoEmailSender, err)
+	}
+}
+
+// fakeCaptcha 只接受固定的凭证
+type fakeCaptcha string
+
+func (f fakeCaptcha) ConsumeToken(ctx context.Context, token string) error {
+	if token != string(f) {
+		return errors.New("凭证不合法")
+	}
+	return nil
+}
+
+func TestCodeService_Captcha(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const biz = "test_captcha"
+	svc := NewCodeService(client,
+		WithCaptcha(fakeCaptcha("token")),
+		WithIPLimiter(limitx.NewLocalFixedWindowLimiter(time.Minute, 2)))
+	phones := []string{"13800138000", "13800138001", "13800138002"}
+	t.Cleanup(func() {
+		for _, phone := range phones {
+			key := svc.key(biz, phone)
+			client.Del(ctx, key, key+":send:count", key+":verify:count")
+		}
+	})
+
+	// 设置了IP限流，没有携带IP时不能绕过限流
+	if _, err := svc.Send(ctx, biz, phones[0], WithCaptchaToken("token")); !errors.Is(err, ErrClientIPRequired) {
+		t.Fatalf("没有携带IP，want=%v，got=%v", ErrClientIPRequired, err)
+	}
+	if _, err := svc.Send(ctx, biz, phones[0], WithClientIP("5.6.7.8")); !errors.Is(err, ErrCaptchaRequired) {
+		t.Fatalf("没有携带凭证，want=%v，got=%v", ErrCaptchaRequired, err)
+	}
+	if _, err := svc.Send(ctx, biz, phones[0], WithCaptchaToken("wrong"), WithClientIP("5.6.7.8")); !errors.Is(err, ErrCaptchaRequired) {
+		t.Fatalf("凭证不合法，want=%v，got=%v", ErrCaptchaRequired, err)
+	}
+	// 同一个IP轮换手机号，超过IP的限制
+	for _, phone := range phones[:2] {
+		if _, err := svc.Send(ctx, biz, phone, WithCaptchaToken("token"), WithClientIP("1.2.3.4")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := svc.Send(ctx, biz, phones[2], WithCaptchaToken("token"), WithClientIP("1.2.3.4"))
+	if !errors.Is(err, ErrIPLimited) {
+		t.Fatalf("want=%v，got=%v", ErrIPLimited, err)
+	}
+}
@@ -0,0 +1,62 @@
+package codex
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailSender 邮件服务，发送验证码邮件
+type EmailSender interface {
+	// SendCode 发送验证码邮件，实现方可以根据业务场景（biz）选择不同的标题和内容
+	SendCode(ctx context.Context, biz, email, code string) error
+}
+
+// SMTPEmailSender 使用SMTP发送验证码邮件
+type SMTPEmailSender struct {
+	addr    string // SMTP服务器的地址，比如smtp.qq.com:587
+	auth    smtp.Auth
+	from    string // 发件人
+	subject string // 邮件标题
+}
+
+// NewSMTPEmailSender 创建SMTP邮件服务，比如：
+//
+//	NewSMTPEmailSender("smtp.qq.com:587", smtp.PlainAuth("", from, password, "smtp.qq.com"), from, "验证码")
+func NewSMTPEmailSender(addr string, auth smtp.Auth, from, subject string) *SMTPEmailSender {
+	return &SMTPEmailSender{
+		addr:    addr,
+		auth:    auth,
+		from:    from,
+		subject: subject,
+	}
+}
+
+// SendCode 发送纯文本的验证码邮件，net/smtp不支持ctx，ctx只用来检查是否已经结束
+func (s *SMTPEmailSender) SendCode(ctx context.Context, biz, email, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(email, "\r\n") {
+		return fmt.Errorf("邮箱地址不合法，email：%q", email)
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{email}, s.message(email, code, time.Now()))
+}
+
+// message 生成邮件的内容
+//
+//	邮件头只能包含ASCII字符，中文标题使用RFC 2047编码（=?utf-8?q?...?=），否则部分邮件客户端会显示乱码
+func (s *SMTPEmailSender) message(email, code string, now time.Time) []byte {
+	msg := "From: " + s.from + "\r\n" +
+		"To: " + email + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", s.subject) + "\r\n" +
+		"Date: " + now.Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		"您的验证码是：" + code + "，请勿泄露给他人。\r\n"
+	return []byte(msg)
+}
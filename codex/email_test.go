@@ -0,0 +1,52 @@
+package codex
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+func TestSMTPEmailSender_Message(t *testing.T) {
+	s := NewSMTPEmailSender("smtp.example.com:587", nil, "noreply@example.com", "登录验证码")
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	raw := s.message("alice@example.com", "123456", now)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 邮件头只包含ASCII字符
+	header, _, _ := bytes.Cut(raw, []byte("\r\n\r\n"))
+	for _, b := range header {
+		if b >= 0x80 {
+			t.Fatalf("邮件头不应该包含非ASCII字符，got=%s", header)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "登录验证码" {
+		t.Fatalf("标题解码错误，got=%s，err=%v", subject, err)
+	}
+	if got := msg.Header.Get("MIME-Version"); got != "1.0" {
+		t.Fatalf("MIME-Version，want=1.0，got=%s", got)
+	}
+	date, err := msg.Header.Date()
+	if err != nil || !date.Equal(now) {
+		t.Fatalf("Date，want=%v，got=%v，err=%v", now, date, err)
+	}
+	body, _ := io.ReadAll(msg.Body)
+	if !bytes.Contains(body, []byte("123456")) {
+		t.Fatalf("邮件内容应该包含验证码，got=%s", body)
+	}
+}
+
+func TestSMTPEmailSender_InvalidEmail(t *testing.T) {
+	s := NewSMTPEmailSender("smtp.example.com:587", nil, "noreply@example.com", "验证码")
+	// 邮箱地址包含换行，可以注入邮件头，不连接SMTP服务器
+	if err := s.SendCode(context.Background(), "login", "alice@example.com\r\nBcc: eve@example.com", "123456"); err == nil {
+		t.Fatal("邮箱地址包含换行，应该返回error")
+	}
+}
@@ -0,0 +1,86 @@
+package codex
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidPolicy 验证码的策略不合法
+var ErrInvalidPolicy = errors.New("codex: 验证码的策略不合法")
+
+// 业务场景
+const (
+	BizLogin         = "login"
+	BizPayment       = "payment"
+	BizResetPassword = "reset_password"
+)
+
+// Policy 验证码的策略，不同的业务场景可以使用不同的策略
+//
+//	lua脚本以秒为单位设置过期时间，CodeTTL、QuotaWindow必须至少1秒，不足1秒时EXPIRE 0会立即删除key；
+//	ResendInterval为0表示没有发送间隔，不为0时也必须至少1秒；位数、发送次数和验证次数必须大于0
+type Policy struct {
+	CodeLength     int           // 验证码的位数
+	CodeTTL        time.Duration // 验证码的有效期
+	ResendInterval time.Duration // 两次发送的最小间隔
+	DailyLimit     int           // 统计周期内最多发送的次数
+	QuotaWindow    time.Duration // 发送次数的统计周期
+	MaxAttempts    int           // 一个验证码最多验证的次数
+}
+
+// DefaultPolicy 默认的策略，和原来的lua脚本中写死的参数一致
+//
+//	验证码6位，有效期10分钟，1分钟内只能发送一条，24小时内最多发送5条，最多验证3次
+var DefaultPolicy = Policy{
+	CodeLength:     6,
+	CodeTTL:        10 * time.Minute,
+	ResendInterval: time.Minute,
+	DailyLimit:     5,
+	QuotaWindow:    24 * time.Hour,
+	MaxAttempts:    3,
+}
+
+// DefaultPolicies 内置业务场景的策略
+//
+//	登录：使用默认的策略
+//	支付：有效期更短，验证次数更少
+//	重置密码：发送次数更少，避免被用来骚扰用户
+var DefaultPolicies = map[string]Policy{
+	BizLogin: DefaultPolicy,
+	BizPayment: {
+		CodeLength:     6,
+		CodeTTL:        5 * time.Minute,
+		ResendInterval: time.Minute,
+		DailyLimit:     10,
+		QuotaWindow:    24 * time.Hour,
+		MaxAttempts:    2,
+	},
+	BizResetPassword: {
+		CodeLength:     6,
+		CodeTTL:        15 * time.Minute,
+		ResendInterval: 2 * time.Minute,
+		DailyLimit:     3,
+		QuotaWindow:    24 * time.Hour,
+		MaxAttempts:    3,
+	},
+}
+
+// validate 校验策略的参数，不合法时返回ErrInvalidPolicy
+func (p Policy) validate() error {
+	switch {
+	case p.CodeLength <= 0:
+		return fmt.Errorf("%w，验证码的位数必须大于0，got=%d", ErrInvalidPolicy, p.CodeLength)
+	case p.CodeTTL < time.Second:
+		return fmt.Errorf("%w，验证码的有效期至少1秒，got=%s", ErrInvalidPolicy, p.CodeTTL)
+	case p.ResendInterval < 0 || (p.ResendInterval > 0 && p.ResendInterval < time.Second):
+		return fmt.Errorf("%w，发送间隔为0或者至少1秒，got=%s", ErrInvalidPolicy, p.ResendInterval)
+	case p.DailyLimit <= 0:
+		return fmt.Errorf("%w，发送次数必须大于0，got=%d", ErrInvalidPolicy, p.DailyLimit)
+	case p.QuotaWindow < time.Second:
+		return fmt.Errorf("%w，发送次数的统计周期至少1秒，got=%s", ErrInvalidPolicy, p.QuotaWindow)
+	case p.MaxAttempts <= 0:
+		return fmt.Errorf("%w，验证次数必须大于0，got=%d", ErrInvalidPolicy, p.MaxAttempts)
+	}
+	return nil
+}
@@ -1,11 +1,22 @@
--- Eval(ctx, setCodeLua, []string{key}, code).Int()
+-- Run(ctx, cmd, []string{key}, code, codeTTL, resendInterval, dailyLimit, quotaWindow).Int()
 -- 获取KEYS数组中的第一个元素，其实就是获取[]string{key}中的第一个元素，即key
 local key = KEYS[1]
 
--- Eval(ctx, setCodeLua, []string{key}, code).Int()
 -- 获取ARGV数组中的第一个元素，即code
 local code = ARGV[1]
 
+-- 验证码的有效期（秒），比如600
+local codeTTL = tonumber(ARGV[2])
+
+-- 两次发送的最小间隔（秒），比如60
+local resendInterval = tonumber(ARGV[3])
+
+-- 统计周期内最多发送的次数，比如5
+local dailyLimit = tonumber(ARGV[4])
+
+-- 发送次数的统计周期（秒），比如86400
+local quotaWindow = tonumber(ARGV[5])
+
 -- 创建keyCount，即"用户剩余发送短信次数"
 local keyCount = key..":send:count" -- ..是字符串连接符
 
@@ -17,11 +28,11 @@ local ttl = tonumber(redis.call("ttl", key))
 
 -- 获取当前keyCount的值
 local count = tonumber(redis.call("get", keyCount))
--- keyCount不存在，初始化为5
+-- keyCount不存在，初始化为dailyLimit
 if not count then
-    redis.call("set", keyCount, 5)
-    redis.call("expire", keyCount, 86400) -- 设置24h的过期时间
-    count = 5
+    redis.call("set", keyCount, dailyLimit)
+    redis.call("expire", keyCount, quotaWindow) -- 设置统计周期的过期时间
+    count = dailyLimit
 end
 
 -- 不允许发送短息
@@ -30,18 +41,20 @@ if ttl == -1 then
     return -1 -- 系统错误
 
 -- 允许发送短息
--- (key不存在 or key的有效期过去1分钟了) and 用户剩余发送短信次数>0
-elseif (ttl == -2 or ttl <= 540) and count > 0 then  -- 540
+-- (key不存在 or 距离上一次发送超过了resendInterval) and 用户剩余发送短信次数>0
+elseif (ttl == -2 or ttl <= codeTTL - resendInterval) and count > 0 then
     -- 更新key和keyCount
     redis.call("set", key, code) -- 设置key的值为code
-    redis.call("expire", key, 600) -- 设置key的过期时间为600秒
+    redis.call("expire", key, codeTTL) -- 设置key的过期时间为codeTTL秒
     redis.call("decr", keyCount) -- 减少剩余发送次数
+    -- 新的验证码重新计算验证次数
+    redis.call("del", key..":verify:count")
     return 0 -- 成功发送短信
 
-elseif ttl > 540 then -- 540
-    return -2 -- 发送太频繁，1分钟内只能发一条
+elseif ttl > codeTTL - resendInterval then
+    return -2 -- 发送太频繁，resendInterval内只能发一条
 
-elseif count == 0 then
-    return -3 -- 今日已经发送5次短信了，发送次数超过上限
+else
+    return -3 -- 统计周期内发送次数超过上限
 
 end
@@ -0,0 +1,232 @@
+package codex
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	//go:embed totp_replay.lua
+	luaTOTPReplay string
+	//go:embed totp_attempt.lua
+	luaTOTPAttempt string
+
+	totpReplayScript  = redis.NewScript(luaTOTPReplay)
+	totpAttemptScript = redis.NewScript(luaTOTPAttempt)
+)
+
+var (
+	ErrTOTPReplayed        = errors.New("codex: 动态口令已经使用过")
+	ErrTOTPInvalidSecret   = errors.New("codex: 动态口令的密钥不合法")
+	ErrTOTPTooManyAttempts = errors.New("codex: 动态口令输入错误的次数太多，请稍后再试")
+)
+
+// TOTPService 基于时间的动态口令（RFC 6238），配合Google Authenticator等验证器使用
+//
+//	用户绑定时，服务端生成密钥（Enroll），把otpauth://格式的URI展示为二维码，用户使用验证器扫码，
+//	密钥由调用方加密保存到数据库中；登录时，使用密钥校验验证器上的6位数字（Verify）
+//	为了兼容客户端和服务端的时间误差，允许前后skew个时间步的动态口令，
+//	验证成功的时间步保存在redis中，同一个动态口令不能使用两次
+//	6位的动态口令只有100万种可能，和短信验证码一样限制验证次数：统计周期内最多验证maxAttempts次，
+//	超过之后返回ErrTOTPTooManyAttempts，直到统计周期结束，验证成功后重新计数
+type TOTPService struct {
+	cmd         redis.Cmdable
+	issuer      string        // 发行方，显示在验证器中，比如公司或者产品的名称
+	period      time.Duration // 时间步的长度
+	digits      int           // 动态口令的位数
+	skew        int           // 允许的时间偏移（时间步的个数）
+	maxAttempts int           // 统计周期内最多验证的次数
+	window      time.Duration // 验证次数的统计周期
+	now         func() time.Time
+}
+
+// TOTPOption TOTP的配置选项
+type TOTPOption func(*TOTPService)
+
+// WithTOTPPeriod 设置时间步的长度，默认是30秒
+//
+//	otpauth://中的period是秒数，时间步的长度必须是整秒，不足1秒或者不是整秒时panic
+func WithTOTPPeriod(period time.Duration) TOTPOption {
+	if period < time.Second || period%time.Second != 0 {
+		panic("codex: 动态口令的时间步必须是整秒，并且至少1秒")
+	}
+	return func(t *TOTPService) {
+		t.period = period
+	}
+}
+
+// WithTOTPDigits 设置动态口令的位数，默认是6位
+//
+//	动态截断得到的是31位的整数，最多取9位，不在1~9之间时panic
+func WithTOTPDigits(digits int) TOTPOption {
+	if digits < 1 || digits > 9 {
+		panic("codex: 动态口令的位数必须在1~9之间")
+	}
+	return func(t *TOTPService) {
+		t.digits = digits
+	}
+}
+
+// WithTOTPSkew 设置允许的时间偏移，默认是1，即允许前后各1个时间步（30秒）的动态口令，skew<0时panic
+func WithTOTPSkew(skew int) TOTPOption {
+	if skew < 0 {
+		panic("codex: 动态口令的时间偏移不能小于0")
+	}
+	return func(t *TOTPService) {
+		t.skew = skew
+	}
+}
+
+// WithTOTPMaxAttempts 设置统计周期内最多验证的次数，默认是5分钟内最多验证5次
+//
+//	lua脚本以秒为单位设置过期时间，attempts<=0或者window不足1秒时panic
+func WithTOTPMaxAttempts(attempts int, window time.Duration) TOTPOption {
+	if attempts <= 0 || window < time.Second {
+		panic("codex: 动态口令的验证次数必须大于0，统计周期至少1秒")
+	}
+	return func(t *TOTPService) {
+		t.maxAttempts = attempts
+		t.window = window
+	}
+}
+
+func NewTOTPService(cmd redis.Cmdable, issuer string, opts ...TOTPOption) *TOTPService {
+	t := &TOTPService{
+		cmd:         cmd,
+		issuer:      issuer,
+		period:      30 * time.Second,
+		digits:      6,
+		skew:        1,
+		maxAttempts: 5,
+		window:      5 * time.Minute,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Enroll 为账号生成密钥，返回base32编码的密钥和otpauth://格式的URI
+//
+//	密钥需要调用方保存，URI用来生成二维码，用户使用验证器扫码绑定
+func (t *TOTPService) Enroll(account string) (secret, uri string, err error) {
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	return secret, t.URI(account, secret), nil
+}
+
+// URI 生成otpauth://格式的URI，比如：
+//
+//	otpauth://totp/GoToolkit:alice@example.com?algorithm=SHA1&digits=6&issuer=GoToolkit&period=30&secret=...
+func (t *TOTPService) URI(account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", t.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(t.digits))
+	query.Set("period", fmt.Sprint(int64(t.period.Seconds())))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + t.issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Verify 校验动态口令，验证成功返回nil
+//
+//	account 用于防重放、限制验证次数的账号，secret Enroll生成的密钥，code 用户输入的动态口令
+//	验证的次数太多时返回ErrTOTPTooManyAttempts，不再校验动态口令
+func (t *TOTPService) Verify(ctx context.Context, account, secret, code string) error {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return err
+	}
+	attemptKey := t.attemptKey(account)
+	res, err := totpAttemptScript.Run(ctx, t.cmd, []string{attemptKey},
+		t.maxAttempts, int64(t.window.Seconds())).Int()
+	if err != nil {
+		return err
+	}
+	if res != 0 {
+		return ErrTOTPTooManyAttempts
+	}
+	current := uint64(t.now().Unix() / int64(t.period.Seconds()))
+	for i := -t.skew; i <= t.skew; i++ {
+		counter := current + uint64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, counter, t.digits)), []byte(code)) != 1 {
+			continue
+		}
+		// 超过偏移窗口之后，这个时间步的动态口令不可能再通过验证，不需要继续保存
+		ttl := int64(t.period.Seconds()) * int64(2*t.skew+1)
+		res, err := totpReplayScript.Run(ctx, t.cmd, []string{t.key(account)}, counter, ttl).Int()
+		if err != nil {
+			return err
+		}
+		if res != 0 {
+			return ErrTOTPReplayed
+		}
+		// 验证成功，重新计数；删除失败时计数在统计周期结束后过期
+		t.cmd.Del(ctx, attemptKey)
+		return nil
+	}
+	return ErrCodeMismatch
+}
+
+func (t *TOTPService) key(account string) string {
+	return "totp_used:" + account
+}
+
+func (t *TOTPService) attemptKey(account string) string {
+	return "totp_attempts:" + account
+}
+
+// GenerateTOTPSecret 生成160位的随机密钥，使用不带填充的base32编码（验证器要求的格式）
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// decodeTOTPSecret 解码base32编码的密钥，兼容小写、空格和填充
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrTOTPInvalidSecret
+	}
+	return key, nil
+}
+
+// hotp 计算第counter个时间步的动态口令（RFC 4226）
+func hotp(key []byte, counter uint64, digits int) string {
+	mac := hmac.New(sha1.New, key)
+	mac.Write(binary.BigEndian.AppendUint64(nil, counter))
+	sum := mac.Sum(nil)
+	// 动态截断，取最后一个字节的低4位作为偏移量，从偏移量开始取4个字节
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,25 @@
+-- TOTP的验证次数限制：统计周期内每个账号最多验证maxAttempts次，验证成功后由调用方删除计数
+-- Run(ctx, cmd, []string{key}, maxAttempts, window).Int()
+-- 返回0：可以验证，-1：验证的次数太多
+
+-- 验证次数的key
+local key = KEYS[1]
+
+-- 统计周期内最多验证的次数，比如5
+local maxAttempts = tonumber(ARGV[1])
+
+-- 统计周期（秒），比如300，从第一次验证开始计算
+local window = tonumber(ARGV[2])
+
+local count = tonumber(redis.call("get", key))
+if count and count >= maxAttempts then
+    -- 次数用完之后不再增加计数，统计周期结束后自动解除
+    return -1
+end
+
+-- 先占用一次验证次数，再校验动态口令，并发的请求不会超过maxAttempts次
+count = redis.call("incr", key)
+if count == 1 then
+    redis.call("expire", key, window)
+end
+return 0
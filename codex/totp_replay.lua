@@ -0,0 +1,19 @@
+-- TOTP的防重放：记录每个账号最后一次验证成功的时间步，同一个时间步和更早的时间步不能再次使用
+-- Run(ctx, cmd, []string{key}, counter, ttl).Int()
+
+-- 最后一次验证成功的时间步
+local key = KEYS[1]
+
+-- 本次验证成功的时间步
+local counter = tonumber(ARGV[1])
+
+-- 过期时间（秒），超过偏移窗口之后，旧的时间步不可能再通过验证
+local ttl = tonumber(ARGV[2])
+
+local last = tonumber(redis.call("get", key))
+if last and last >= counter then
+    -- 验证码已经使用过
+    return -1
+end
+redis.call("set", key, counter, "EX", ttl)
+return 0
@@ -0,0 +1,174 @@
+package codex
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHOTP RFC 6238附录B的测试数据（SHA1，8位）
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tc := range testCases {
+		if got := hotp(key, uint64(tc.unix/30), 8); got != tc.want {
+			t.Fatalf("unix=%d，want=%s，got=%s", tc.unix, tc.want, got)
+		}
+	}
+}
+
+func TestTOTPService_URI(t *testing.T) {
+	svc := NewTOTPService(nil, "GoToolkit")
+	secret, uri, err := svc.Enroll("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("160位的密钥，base32编码后是32个字符，got=%d", len(secret))
+	}
+	want := "otpauth://totp/GoToolkit:alice@example.com?algorithm=SHA1&digits=6&issuer=GoToolkit&period=30&secret=" + secret
+	if uri != want {
+		t.Fatalf("want=%s，got=%s", want, uri)
+	}
+}
+
+func TestTOTPService_Verify(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const account = "test_totp"
+	t.Cleanup(func() {
+		client.Del(ctx, "totp_used:"+account, "totp_attempts:"+account)
+	})
+	client.Del(ctx, "totp_used:"+account, "totp_attempts:"+account)
+
+	key := []byte("12345678901234567890")
+	secret := strings.ToLower(base32.StdEncoding.EncodeToString(key))
+	now := time.Unix(1111111111, 0)
+	svc := NewTOTPService(client, "GoToolkit")
+	svc.now = func() time.Time { return now }
+	code := func(offset int) string {
+		return hotp(key, uint64(now.Unix()/30+int64(offset)), 6)
+	}
+
+	// 客户端的时间慢了一个时间步，仍然可以通过验证
+	if err := svc.Verify(ctx, account, secret, code(-1)); err != nil {
+		t.Fatalf("偏移窗口内的动态口令应该通过验证，err=%v", err)
+	}
+	if err := svc.Verify(ctx, account, secret, code(0)); err != nil {
+		t.Fatalf("当前的动态口令应该通过验证，err=%v", err)
+	}
+	// 同一个动态口令，或者更早的动态口令，不能再次使用
+	if err := svc.Verify(ctx, account, secret, code(0)); !errors.Is(err, ErrTOTPReplayed) {
+		t.Fatalf("want=%v，got=%v", ErrTOTPReplayed, err)
+	}
+	if err := svc.Verify(ctx, account, secret, code(-1)); !errors.Is(err, ErrTOTPReplayed) {
+		t.Fatalf("want=%v，got=%v", ErrTOTPReplayed, err)
+	}
+	// 超出偏移窗口
+	if err := svc.Verify(ctx, account, secret, code(2)); !errors.Is(err, ErrCodeMismatch) {
+		t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+	}
+	if err := svc.Verify(ctx, account, "not base32!", code(0)); !errors.Is(err, ErrTOTPInvalidSecret) {
+		t.Fatalf("want=%v，got=%v", ErrTOTPInvalidSecret, err)
+	}
+}
+
+// TestTOTPService_MaxAttempts 统计周期内输错的次数太多时，正确的动态口令也不能通过验证，验证成功后重新计数
+func TestTOTPService_MaxAttempts(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const account = "test_totp_attempts"
+	attemptKey := "totp_attempts:" + account
+	t.Cleanup(func() {
+		client.Del(ctx, "totp_used:"+account, attemptKey)
+	})
+	client.Del(ctx, "totp_used:"+account, attemptKey)
+
+	key := []byte("12345678901234567890")
+	secret := base32.StdEncoding.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	svc := NewTOTPService(client, "GoToolkit", WithTOTPMaxAttempts(3, time.Minute))
+	svc.now = func() time.Time { return now }
+	code := func(offset int) string {
+		return hotp(key, uint64(now.Unix()/30+int64(offset)), 6)
+	}
+
+	// 输错2次之后验证成功，重新计数
+	for i := 0; i < 2; i++ {
+		if err := svc.Verify(ctx, account, secret, "000000"); !errors.Is(err, ErrCodeMismatch) {
+			t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+		}
+	}
+	if err := svc.Verify(ctx, account, secret, code(-1)); err != nil {
+		t.Fatalf("没有超过验证次数，应该验证成功，err=%v", err)
+	}
+	if n, _ := client.Exists(ctx, attemptKey).Result(); n != 0 {
+		t.Fatal("验证成功后应该删除验证次数")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := svc.Verify(ctx, account, secret, "000000"); !errors.Is(err, ErrCodeMismatch) {
+			t.Fatalf("want=%v，got=%v", ErrCodeMismatch, err)
+		}
+	}
+	if err := svc.Verify(ctx, account, secret, code(0)); !errors.Is(err, ErrTOTPTooManyAttempts) {
+		t.Fatalf("want=%v，got=%v", ErrTOTPTooManyAttempts, err)
+	}
+	if ttl := client.TTL(ctx, attemptKey).Val(); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("验证次数应该在统计周期结束后过期，got=%s", ttl)
+	}
+	// 统计周期结束后，可以重新验证
+	client.Del(ctx, attemptKey)
+	if err := svc.Verify(ctx, account, secret, code(0)); err != nil {
+		t.Fatalf("统计周期结束后，应该验证成功，err=%v", err)
+	}
+}
+
+// TestTOTPService_InvalidOptions 时间步的长度、动态口令的位数、时间偏移不合法时panic
+func TestTOTPService_InvalidOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opt  func() TOTPOption
+	}{
+		{name: "时间步为0", opt: func() TOTPOption { return WithTOTPPeriod(0) }},
+		{name: "时间步不足1秒", opt: func() TOTPOption { return WithTOTPPeriod(500 * time.Millisecond) }},
+		{name: "时间步不是整秒", opt: func() TOTPOption { return WithTOTPPeriod(1500 * time.Millisecond) }},
+		{name: "位数为0", opt: func() TOTPOption { return WithTOTPDigits(0) }},
+		{name: "位数超过9", opt: func() TOTPOption { return WithTOTPDigits(10) }},
+		{name: "偏移为负数", opt: func() TOTPOption { return WithTOTPSkew(-1) }},
+		{name: "验证次数为0", opt: func() TOTPOption { return WithTOTPMaxAttempts(0, time.Minute) }},
+		{name: "统计周期不足1秒", opt: func() TOTPOption { return WithTOTPMaxAttempts(5, time.Millisecond) }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("配置不合法，应该panic")
+				}
+			}()
+			NewTOTPService(nil, "GoToolkit", tc.opt())
+		})
+	}
+
+	// 合法的边界值
+	svc := NewTOTPService(nil, "GoToolkit", WithTOTPPeriod(time.Second), WithTOTPDigits(9), WithTOTPSkew(0))
+	if svc.period != time.Second || svc.digits != 9 || svc.skew != 0 {
+		t.Fatalf("合法的配置应该生效，got period=%v digits=%d skew=%d", svc.period, svc.digits, svc.skew)
+	}
+	if code := hotp([]byte("12345678901234567890"), 1, 9); len(code) != 9 {
+		t.Fatalf("应该生成9位的动态口令，got=%s", code)
+	}
+}
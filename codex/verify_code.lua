@@ -1,27 +1,42 @@
--- Eval(ctx, setCodeLua, []string{key}, code).Int()
+-- Run(ctx, cmd, []string{key}, inputCode, maxAttempts).Int()
+-- 返回0：验证成功，-1：输入错误，-2：输入错误的次数太多，-3：验证码不存在或者已经过期
 -- 获取KEYS数组中的第一个元素，key
 local key = KEYS[1]
 
 -- 获取ARGV数组中的第一个元素，code
 local inputCode = ARGV[1]
 
+-- 最多尝试的次数，比如3
+local maxAttempts = tonumber(ARGV[2])
+
 -- 获取key的值，也就是redis中存储的code
+-- key不存在时，redis.call返回false
 local code = redis.call("get", key)
 
--- 创建发送次数的key，即key:count
+-- 验证码不存在或者已经过期，和输入错误区分开，调用方可以提示用户重新获取验证码
+if not code then
+    return -3
+end
+
+-- 创建验证次数的key，即key:verify:count
 local keyCount = key..":verify:count"
 
 -- 获取还可以验证的次数
 local count = tonumber(redis.call("get", keyCount))
 
--- 如果没有获取到count，并且code不为nil，则初始化为3（假设最多尝试次数为3）
-if count == nil and code ~= nil then
-    count = 3 -- 初始化count
+-- 如果没有获取到count，则初始化为maxAttempts
+if count == nil then
+    count = maxAttempts
     redis.call("set", keyCount, count)
+    -- 和验证码同时过期，避免验证次数的key永久存在
+    local ttl = tonumber(redis.call("ttl", key))
+    if ttl > 0 then
+        redis.call("expire", keyCount, ttl)
+    end
 end
 
 -- 用户一直输错
-if count == 0 then
+if count <= 0 then
     -- 返回"输入错误次数太多，稍后重试"
     redis.call("del", key)
     redis.call("del", keyCount)
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyMiddlewareBuilder 并发限流中间件，限制同时在处理的请求数量，请求处理结束后释放槽位
+type ConcurrencyMiddlewareBuilder struct {
+	paths   []string
+	limiter limitx.ConcurrencyLimiter
+	keyFunc KeyFunc
+	logger  loggerx.Logger
+}
+
+func NewConcurrencyMiddlewareBuilder(limiter limitx.ConcurrencyLimiter, keyFunc KeyFunc,
+	logger loggerx.Logger) *ConcurrencyMiddlewareBuilder {
+	return &ConcurrencyMiddlewareBuilder{
+		limiter: limiter,
+		keyFunc: keyFunc,
+		logger:  logger,
+	}
+}
+
+// IgnorePath 不需要限流的路径
+func (c *ConcurrencyMiddlewareBuilder) IgnorePath(path string) *ConcurrencyMiddlewareBuilder {
+	c.paths = append(c.paths, path)
+	return c
+}
+
+func (c *ConcurrencyMiddlewareBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 检查当前路由是否需要限流
+		for _, path := range c.paths {
+			if path == ctx.Request.URL.Path {
+				return
+			}
+		}
+		key := c.keyFunc(ctx)
+		if key == "" {
+			return
+		}
+		release, limit, err := c.limiter.Acquire(ctx.Request.Context(), key)
+		if err != nil {
+			// 保守策略，拒绝请求
+			c.logger.Error("并发限流器执行失败", loggerx.Error(err),
+				loggerx.String("path", ctx.Request.URL.Path),
+				loggerx.String("key", key))
+			abortTooManyRequests(ctx)
+			return
+		}
+		if limit {
+			c.logger.Warn("触发并发限流",
+				loggerx.String("path", ctx.Request.URL.Path),
+				loggerx.String("key", key))
+			abortTooManyRequests(ctx)
+			return
+		}
+		// 后续的处理结束（包括panic）后释放槽位
+		defer release()
+		ctx.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"GoToolkit/limitx"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrencyMiddlewareBuilder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := limitx.NewLocalConcurrencyLimiter(1)
+	server := gin.New()
+	server.Use(gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, err any) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	server.Use(NewConcurrencyMiddlewareBuilder(limiter, KeyConst("upload"), nopLogger{}).
+		IgnorePath("/health").Builder())
+	entered, done := make(chan struct{}), make(chan struct{})
+	server.POST("/upload", func(ctx *gin.Context) {
+		close(entered)
+		<-done
+		ctx.Status(http.StatusOK)
+	})
+	server.GET("/health", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	server.GET("/panic", func(ctx *gin.Context) {
+		panic("panic")
+	})
+	serve := func(method, path string) int {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder.Code
+	}
+
+	// 第一个请求持有唯一的槽位
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if code := serve(http.MethodPost, "/upload"); code != http.StatusOK {
+			t.Errorf("第一个请求应该放行，code=%d", code)
+		}
+	}()
+	<-entered
+	if code := serve(http.MethodGet, "/panic"); code != http.StatusTooManyRequests {
+		t.Fatalf("没有空闲的槽位，应该限流，code=%d", code)
+	}
+	if code := serve(http.MethodGet, "/health"); code != http.StatusOK {
+		t.Fatalf("忽略的路径不应该限流，code=%d", code)
+	}
+	close(done)
+	wg.Wait()
+	// 请求处理结束（包括panic）后释放槽位
+	if code := serve(http.MethodGet, "/panic"); code != http.StatusInternalServerError {
+		t.Fatalf("第一个请求结束后，应该放行，code=%d", code)
+	}
+	if code := serve(http.MethodGet, "/panic"); code != http.StatusInternalServerError {
+		t.Fatalf("panic之后应该释放槽位，code=%d", code)
+	}
+}
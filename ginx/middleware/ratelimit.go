@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// KeyFunc 从请求中提取限流对象，返回空字符串表示不限流
+type KeyFunc func(ctx *gin.Context) string
+
+// RateLimitMiddlewareBuilder 限流中间件
+type RateLimitMiddlewareBuilder struct {
+	paths   []string
+	limiter limitx.Limiter
+	keyFunc KeyFunc
+	logger  loggerx.Logger
+}
+
+func NewRateLimitMiddlewareBuilder(limiter limitx.Limiter, keyFunc KeyFunc,
+	logger loggerx.Logger) *RateLimitMiddlewareBuilder {
+	return &RateLimitMiddlewareBuilder{
+		limiter: limiter,
+		keyFunc: keyFunc,
+		logger:  logger,
+	}
+}
+
+// IgnorePath 不需要限流的路径
+func (r *RateLimitMiddlewareBuilder) IgnorePath(path string) *RateLimitMiddlewareBuilder {
+	r.paths = append(r.paths, path)
+	return r
+}
+
+func (r *RateLimitMiddlewareBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 检查当前路由是否需要限流
+		for _, path := range r.paths {
+			if path == ctx.Request.URL.Path {
+				return
+			}
+		}
+		key := r.keyFunc(ctx)
+		if key == "" {
+			return
+		}
+		// 使用请求的上下文，请求结束时上下文会被取消
+		decision, limited, err := r.limit(ctx, key)
+		if err != nil {
+			// 保守策略，拒绝请求
+			r.logger.Error("限流器执行失败", loggerx.Error(err),
+				loggerx.String("path", ctx.Request.URL.Path),
+				loggerx.String("key", key))
+			abortTooManyRequests(ctx)
+			return
+		}
+		if decision != nil {
+			SetRateLimitHeaders(ctx, *decision)
+		}
+		if limited {
+			r.logger.Warn("触发限流，可能有人在攻击你的系统",
+				loggerx.String("path", ctx.Request.URL.Path),
+				loggerx.String("key", key))
+			abortTooManyRequests(ctx)
+			return
+		}
+	}
+}
+
+// limit 判断是否限流，限流器实现了limitx.DecisionLimiter时，同时返回限流决策
+func (r *RateLimitMiddlewareBuilder) limit(ctx *gin.Context, key string) (*limitx.Decision, bool, error) {
+	dl, ok := r.limiter.(limitx.DecisionLimiter)
+	if !ok {
+		limited, err := r.limiter.Limit(ctx.Request.Context(), key)
+		return nil, limited, err
+	}
+	decision, err := dl.Allow(ctx.Request.Context(), key)
+	if err != nil {
+		return nil, false, err
+	}
+	return &decision, !decision.Allowed, nil
+}
+
+// SetRateLimitHeaders 设置X-RateLimit-*响应头，被限流时同时设置Retry-After（秒）
+func SetRateLimitHeaders(ctx *gin.Context, decision limitx.Decision) {
+	ctx.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+	ctx.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	if !decision.ResetAt.IsZero() {
+		ctx.Header("X-RateLimit-Reset", strconv.FormatInt(decision.ResetAt.Unix(), 10))
+	}
+	if !decision.Allowed && decision.RetryAfter > 0 {
+		// Retry-After只支持整数秒，向上取整
+		seconds := int64(math.Ceil(decision.RetryAfter.Seconds()))
+		ctx.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+}
+
+// abortTooManyRequests 返回429，并终止后续的处理
+func abortTooManyRequests(ctx *gin.Context) {
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, Result[string]{
+		Code: http.StatusTooManyRequests,
+		Msg:  "请求过于频繁，请稍后再试",
+		Data: "error",
+	})
+}
+
+// KeyByClientIP 按照客户端IP限流
+func KeyByClientIP(prefix string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return prefix + ":ip:" + ctx.ClientIP()
+	}
+}
+
+// KeyByFullPath 按照路由限流，使用注册的路由（比如/users/:id），而不是实际的请求路径
+func KeyByFullPath(prefix string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		path := ctx.FullPath()
+		// 路由未找到
+		if path == "" {
+			path = "unknown"
+		}
+		return prefix + ":path:" + ctx.Request.Method + ":" + path
+	}
+}
+
+// KeyByHeader 按照请求头的值限流，比如按照AppId、设备号限流，请求头不存在时不限流
+func KeyByHeader(prefix, header string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		val := ctx.GetHeader(header)
+		if val == "" {
+			return ""
+		}
+		return prefix + ":header:" + header + ":" + val
+	}
+}
+
+// KeyByUserId 按照用户id限流，需要放在JwtMiddlewareBuilder之后，用户没有登录时不限流
+func KeyByUserId(prefix string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		val, exists := ctx.Get("userClaims")
+		if !exists {
+			return ""
+		}
+		userClaims, ok := val.(UserClaims)
+		if !ok {
+			return ""
+		}
+		return prefix + ":user:" + strconv.FormatInt(userClaims.Id, 10)
+	}
+}
+
+// KeyConst 所有请求使用同一个限流对象，用来做全局限流
+func KeyConst(key string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return key
+	}
+}
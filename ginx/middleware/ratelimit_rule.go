@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// RuleBinding 限流规则，以及按照这条规则限流的对象
+type RuleBinding struct {
+	Rule limitx.Rule
+	Key  KeyFunc // 返回空字符串表示这条规则不限流
+}
+
+// RateLimitRuleBuilder 多维度限流中间件，一个请求同时按照多条规则限流（用户、IP、路由、全局）
+type RateLimitRuleBuilder struct {
+	paths   []string
+	limiter *limitx.RedisCompositeLimiter
+	global  []RuleBinding            // 所有路由都生效的规则
+	routes  map[string][]RuleBinding // 按照"请求方式 路由"声明的规则
+	logger  loggerx.Logger
+}
+
+func NewRateLimitRuleBuilder(limiter *limitx.RedisCompositeLimiter,
+	logger loggerx.Logger) *RateLimitRuleBuilder {
+	return &RateLimitRuleBuilder{
+		limiter: limiter,
+		routes:  make(map[string][]RuleBinding),
+		logger:  logger,
+	}
+}
+
+// IgnorePath 不需要限流的路径
+func (r *RateLimitRuleBuilder) IgnorePath(path string) *RateLimitRuleBuilder {
+	r.paths = append(r.paths, path)
+	return r
+}
+
+// Global 声明所有路由都生效的规则
+func (r *RateLimitRuleBuilder) Global(bindings ...RuleBinding) *RateLimitRuleBuilder {
+	r.global = append(r.global, bindings...)
+	return r
+}
+
+// Route 声明某个路由的规则
+//
+//	method 请求方式，比如http.MethodPost
+//	path   注册的路由，和ctx.FullPath()一致，比如"/users/:id"
+func (r *RateLimitRuleBuilder) Route(method, path string, bindings ...RuleBinding) *RateLimitRuleBuilder {
+	route := method + " " + path
+	r.routes[route] = append(r.routes[route], bindings...)
+	return r
+}
+
+func (r *RateLimitRuleBuilder) Builder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 检查当前路由是否需要限流
+		for _, path := range r.paths {
+			if path == ctx.Request.URL.Path {
+				return
+			}
+		}
+		decision, err := r.limiter.Check(ctx.Request.Context(), r.hits(ctx))
+		if err != nil {
+			// 保守策略，拒绝请求
+			r.logger.Error("限流器执行失败", loggerx.Error(err),
+				loggerx.String("path", ctx.Request.URL.Path))
+			abortTooManyRequests(ctx)
+			return
+		}
+		if !decision.Allowed {
+			r.logger.Warn("触发限流，可能有人在攻击你的系统",
+				loggerx.String("path", ctx.Request.URL.Path),
+				loggerx.String("rule", decision.Rule))
+			ctx.Header("X-RateLimit-Rule", decision.Rule)
+			if decision.RetryAfter > 0 {
+				seconds := int64(math.Ceil(decision.RetryAfter.Seconds()))
+				ctx.Header("Retry-After", strconv.FormatInt(seconds, 10))
+			}
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, Result[string]{
+				Code: http.StatusTooManyRequests,
+				Msg:  "请求过于频繁，请稍后再试",
+				Data: decision.Rule,
+			})
+			return
+		}
+	}
+}
+
+// hits 当前请求命中的规则
+func (r *RateLimitRuleBuilder) hits(ctx *gin.Context) []limitx.Hit {
+	bindings := r.routes[ctx.Request.Method+" "+ctx.FullPath()]
+	hits := make([]limitx.Hit, 0, len(r.global)+len(bindings))
+	for _, bs := range [][]RuleBinding{r.global, bindings} {
+		for _, b := range bs {
+			key := b.Key(ctx)
+			if key == "" {
+				continue
+			}
+			hits = append(hits, limitx.Hit{Rule: b.Rule, Key: key})
+		}
+	}
+	return hits
+}
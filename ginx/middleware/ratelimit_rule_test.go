@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newRedisClient 连接本地的redis，redis不可用时跳过测试
+func newRedisClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis不可用，跳过测试，err=%v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...loggerx.Field) {}
+func (nopLogger) Info(string, ...loggerx.Field)  {}
+func (nopLogger) Warn(string, ...loggerx.Field)  {}
+func (nopLogger) Error(string, ...loggerx.Field) {}
+
+func TestRateLimitRuleBuilder(t *testing.T) {
+	client := newRedisClient(t)
+	gin.SetMode(gin.TestMode)
+	// 每个测试使用不同的hash tag，规则的key在窗口结束后自动过期
+	limiter := limitx.NewRedisCompositeLimiter(client,
+		limitx.WithKeyPrefix("limitx:test"), limitx.WithHashTag(uuid.NewString()))
+	// 全局规则和路由规则使用同一个限流对象（按照IP限流）
+	builder := NewRateLimitRuleBuilder(limiter, nopLogger{}).
+		IgnorePath("/health").
+		Global(RuleBinding{
+			Rule: limitx.Rule{Name: "ip", Interval: time.Minute, Rate: 3},
+			Key:  KeyByClientIP("test"),
+		}).
+		Route(http.MethodPost, "/login", RuleBinding{
+			Rule: limitx.Rule{Name: "login", Interval: time.Minute, Rate: 1},
+			Key:  KeyByClientIP("test"),
+		}, RuleBinding{
+			Rule: limitx.Rule{Name: "device", Interval: time.Minute, Rate: 1},
+			Key:  KeyByHeader("test", "X-Device-Id"),
+		})
+	server := gin.New()
+	server.Use(builder.Builder())
+	ok := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	server.POST("/login", ok)
+	server.GET("/profile", ok)
+	server.GET("/health", ok)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder
+	}
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantRule string
+	}{
+		// 请求头不存在时，device规则不限流
+		{name: "登录", method: http.MethodPost, path: "/login", wantCode: http.StatusOK},
+		{name: "触发路由规则", method: http.MethodPost, path: "/login",
+			wantCode: http.StatusTooManyRequests, wantRule: "login"},
+		// 路由规则不影响其他路由，被限流的请求不占用全局规则的配额
+		{name: "其他路由", method: http.MethodGet, path: "/profile", wantCode: http.StatusOK},
+		{name: "其他路由", method: http.MethodGet, path: "/profile", wantCode: http.StatusOK},
+		{name: "触发全局规则", method: http.MethodGet, path: "/profile",
+			wantCode: http.StatusTooManyRequests, wantRule: "ip"},
+		{name: "忽略的路径", method: http.MethodGet, path: "/health", wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		recorder := serve(tc.method, tc.path)
+		if recorder.Code != tc.wantCode {
+			t.Fatalf("%s，want=%d，got=%d", tc.name, tc.wantCode, recorder.Code)
+		}
+		if got := recorder.Header().Get("X-RateLimit-Rule"); got != tc.wantRule {
+			t.Fatalf("%s，want rule=%q，got=%q", tc.name, tc.wantRule, got)
+		}
+		if tc.wantRule != "" && recorder.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s，触发限流时应该设置Retry-After", tc.name)
+		}
+	}
+}
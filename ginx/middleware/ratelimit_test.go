@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"GoToolkit/limitx"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// recordLimiter 记录限流对象，返回固定的决策
+type recordLimiter struct {
+	keys     []string
+	decision limitx.Decision
+	err      error
+}
+
+func (r *recordLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := r.Allow(ctx, key)
+	return !decision.Allowed, err
+}
+
+func (r *recordLimiter) Allow(ctx context.Context, key string) (limitx.Decision, error) {
+	r.keys = append(r.keys, key)
+	return r.decision, r.err
+}
+
+// plainLimiter 只实现了limitx.Limiter的限流器
+type plainLimiter bool
+
+func (p plainLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return bool(p), nil
+}
+
+func newRateLimitServer(limiter limitx.Limiter, keyFunc KeyFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(NewRateLimitMiddlewareBuilder(limiter, keyFunc, nopLogger{}).
+		IgnorePath("/health").Builder())
+	handler := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	server.GET("/users/:id", handler)
+	server.GET("/health", handler)
+	return server
+}
+
+func TestRateLimitMiddlewareBuilder(t *testing.T) {
+	server := newRateLimitServer(limitx.NewLocalFixedWindowLimiter(time.Hour, 2), KeyConst("test"))
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+	for i := 0; i < 2; i++ {
+		recorder := serve("/users/1")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("第%d个请求应该放行，code=%d", i+1, recorder.Code)
+		}
+		if got := recorder.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Fatalf("X-RateLimit-Limit，want=2，got=%s", got)
+		}
+		if got := recorder.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(1-i) {
+			t.Fatalf("X-RateLimit-Remaining，want=%d，got=%s", 1-i, got)
+		}
+		if recorder.Header().Get("Retry-After") != "" {
+			t.Fatal("放行的请求不应该设置Retry-After")
+		}
+	}
+
+	recorder := serve("/users/1")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("超出阈值应该返回429，code=%d", recorder.Code)
+	}
+	var res Result[string]
+	if err := json.Unmarshal(recorder.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != http.StatusTooManyRequests || res.Msg == "" || res.Data != "error" {
+		t.Fatalf("响应体错误，got=%+v", res)
+	}
+	if got := recorder.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Fatalf("X-RateLimit-Remaining，want=0，got=%s", got)
+	}
+	// 固定窗口在下一个整点重置
+	retryAfter, _ := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	reset, _ := strconv.ParseInt(recorder.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if retryAfter <= 0 || retryAfter > 3600 || reset <= time.Now().Unix() {
+		t.Fatalf("Retry-After=%d，X-RateLimit-Reset=%d", retryAfter, reset)
+	}
+
+	// 忽略的路径不限流，也不设置响应头
+	recorder = serve("/health")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("忽略的路径不应该限流，code=%d", recorder.Code)
+	}
+}
+
+func TestRateLimitMiddlewareBuilder_Decision(t *testing.T) {
+	testCases := []struct {
+		name           string
+		limiter        limitx.Limiter
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{
+			name:           "不足1秒的重试时间向上取整",
+			limiter:        &recordLimiter{decision: limitx.Decision{Limit: 10, RetryAfter: 1200 * time.Millisecond}},
+			wantCode:       http.StatusTooManyRequests,
+			wantRetryAfter: "2",
+		},
+		{
+			name:     "限流器执行失败，拒绝请求",
+			limiter:  &recordLimiter{err: errors.New("redis不可用")},
+			wantCode: http.StatusTooManyRequests,
+		},
+		{
+			name:     "限流器不返回决策，不设置响应头",
+			limiter:  plainLimiter(true),
+			wantCode: http.StatusTooManyRequests,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newRateLimitServer(tc.limiter, KeyConst("test"))
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+			if recorder.Code != tc.wantCode {
+				t.Fatalf("want code=%d，got=%d", tc.wantCode, recorder.Code)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tc.wantRetryAfter {
+				t.Fatalf("Retry-After，want=%q，got=%q", tc.wantRetryAfter, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareBuilder_KeyFunc(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keyFunc KeyFunc
+		setup   func(ctx *gin.Context) // 在限流中间件之前执行，比如登录校验
+		header  http.Header
+		wantKey string // 空字符串表示不限流
+	}{
+		{
+			name:    "按照用户id",
+			keyFunc: KeyByUserId("test"),
+			setup: func(ctx *gin.Context) {
+				ctx.Set("userClaims", UserClaims{Id: 123})
+			},
+			wantKey: "test:user:123",
+		},
+		{
+			name:    "用户没有登录",
+			keyFunc: KeyByUserId("test"),
+		},
+		{
+			name:    "用户信息的类型错误",
+			keyFunc: KeyByUserId("test"),
+			setup: func(ctx *gin.Context) {
+				ctx.Set("userClaims", &UserClaims{Id: 123})
+			},
+		},
+		{
+			name:    "按照请求头",
+			keyFunc: KeyByHeader("test", "X-Device-Id"),
+			header:  http.Header{"X-Device-Id": []string{"abc"}},
+			wantKey: "test:header:X-Device-Id:abc",
+		},
+		{
+			name:    "请求头不存在",
+			keyFunc: KeyByHeader("test", "X-Device-Id"),
+		},
+		{
+			name:    "按照路由",
+			keyFunc: KeyByFullPath("test"),
+			wantKey: "test:path:GET:/users/:id",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			limiter := &recordLimiter{decision: limitx.Decision{Allowed: true}}
+			server := gin.New()
+			if tc.setup != nil {
+				server.Use(tc.setup)
+			}
+			server.Use(NewRateLimitMiddlewareBuilder(limiter, tc.keyFunc, nopLogger{}).Builder())
+			server.GET("/users/:id", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("应该放行，code=%d", recorder.Code)
+			}
+			if tc.wantKey == "" {
+				if len(limiter.keys) != 0 {
+					t.Fatalf("不应该限流，got=%v", limiter.keys)
+				}
+				return
+			}
+			if len(limiter.keys) != 1 || limiter.keys[0] != tc.wantKey {
+				t.Fatalf("want=%s，got=%v", tc.wantKey, limiter.keys)
+			}
+		})
+	}
+}
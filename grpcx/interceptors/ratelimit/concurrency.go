@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConcurrencyInterceptor 并发限流拦截器，限制同时在处理的请求数量，请求处理结束后释放槽位
+type ConcurrencyInterceptor struct {
+	limiter limitx.ConcurrencyLimiter
+	key     KeyExtractor // 返回空字符串表示不限流
+	logger  loggerx.Logger
+}
+
+func NewConcurrencyInterceptor(limiter limitx.ConcurrencyLimiter, key KeyExtractor,
+	logger loggerx.Logger) *ConcurrencyInterceptor {
+	return &ConcurrencyInterceptor{
+		limiter: limiter,
+		key:     key,
+		logger:  logger,
+	}
+}
+
+// BuildServerInterceptor 对服务端限流
+func (i *ConcurrencyInterceptor) BuildServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp any, err error) {
+		key := i.key(ctx, info)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		release, limit, err := i.limiter.Acquire(ctx, key)
+		if err != nil {
+			// 保守法，拒绝请求
+			i.logger.Error("并发限流器执行失败", loggerx.Error(err),
+				loggerx.String("method:", info.FullMethod))
+			return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
+		}
+		if limit {
+			i.logger.Warn("触发并发限流",
+				loggerx.String("method:", info.FullMethod),
+				loggerx.String("key", key))
+			return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
+		}
+		// 业务代码执行结束（包括panic）后释放槽位
+		defer release()
+		return handler(ctx, req)
+	}
+}
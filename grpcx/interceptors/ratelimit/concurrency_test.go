@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"GoToolkit/limitx"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+)
+
+func TestConcurrencyInterceptor(t *testing.T) {
+	limiter := limitx.NewLocalConcurrencyLimiter(1)
+	interceptor := NewConcurrencyInterceptor(limiter, KeyByFullMethod("test"), nopLogger{}).
+		BuildServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/file.v1.FileService/Upload"}
+	entered, done := make(chan struct{}), make(chan struct{})
+	blocking := func(ctx context.Context, req any) (any, error) {
+		close(entered)
+		<-done
+		return "ok", nil
+	}
+	ok := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	panicking := func(ctx context.Context, req any) (any, error) {
+		panic("panic")
+	}
+
+	// 第一个请求持有唯一的槽位
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := interceptor(context.Background(), nil, info, blocking); err != nil {
+			t.Errorf("第一个请求应该放行，err=%v", err)
+		}
+	}()
+	<-entered
+	_, err := interceptor(context.Background(), nil, info, ok)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("没有空闲的槽位，应该限流，err=%v", err)
+	}
+	// 其他方法使用不同的限流对象
+	other := &grpc.UnaryServerInfo{FullMethod: "/file.v1.FileService/List"}
+	if _, err = interceptor(context.Background(), nil, other, ok); err != nil {
+		t.Fatalf("其他方法不应该限流，err=%v", err)
+	}
+	close(done)
+	wg.Wait()
+
+	// 业务代码panic之后也会释放槽位
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("业务代码的panic应该继续向上传递")
+			}
+		}()
+		interceptor(context.Background(), nil, info, panicking)
+	}()
+	if _, err = interceptor(context.Background(), nil, info, ok); err != nil {
+		t.Fatalf("panic之后应该释放槽位，err=%v", err)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"GoToolkit/limitx"
 	"GoToolkit/loggerx"
 	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"strings"
+	"time"
 )
 
 // Interceptor 拦截器
@@ -33,7 +36,7 @@ func NewInterceptor(limiter limitx.Limiter, key string, logger loggerx.Logger,
 func (i *Interceptor) BuildServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler) (resp any, err error) {
-		limit, err := i.limiter.Limit(ctx, i.key)
+		limit, retryAfter, err := i.limit(ctx, i.key)
 		if err != nil {
 			// 保守法，拒绝请求
 			// codes.ResourceExhausted 服务端资源不足
@@ -46,7 +49,7 @@ func (i *Interceptor) BuildServerInterceptor() grpc.UnaryServerInterceptor {
 		if limit == true {
 			i.logger.Warn("触发限流，可能有人在攻击你的系统",
 				loggerx.String("method:", "Interceptor:BuildServerInterceptor"))
-			return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
+			return nil, limitedError(retryAfter)
 		}
 		// 执行下一个拦截器，或者是真实的业务代码
 		return handler(ctx, req)
@@ -80,11 +83,11 @@ func (i *Interceptor) BuildServerInterceptorService() grpc.UnaryServerIntercepto
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler) (resp any, err error) {
 		if strings.HasPrefix(info.FullMethod, "/"+i.serviceName+"/") {
-			limit, er := i.limiter.Limit(ctx, "limiter:service"+i.serviceName)
+			limit, retryAfter, er := i.limit(ctx, "limiter:service"+i.serviceName)
 			if er != nil {
 				// 保守法，拒绝请求
 				// codes.ResourceExhausted 服务端资源不足
-				i.logger.Error("限流器创建失败", loggerx.Error(err),
+				i.logger.Error("限流器创建失败", loggerx.Error(er),
 					loggerx.String("method:", "Interceptor:BuildServerInterceptor"))
 				return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
 				// 激进策略
@@ -93,10 +96,40 @@ func (i *Interceptor) BuildServerInterceptorService() grpc.UnaryServerIntercepto
 			if limit == true {
 				i.logger.Warn("触发限流，可能有人在攻击你的系统",
 					loggerx.String("method:", "Interceptor:BuildServerInterceptor"))
-				return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
+				return nil, limitedError(retryAfter)
 			}
 		}
 		// 执行下一个拦截器，或者是真实的业务代码
 		return handler(ctx, req)
 	}
 }
+
+// limit 判断是否限流，限流器实现了limitx.DecisionLimiter时，同时返回需要等待的时间
+func (i *Interceptor) limit(ctx context.Context, key string) (bool, time.Duration, error) {
+	dl, ok := i.limiter.(limitx.DecisionLimiter)
+	if !ok {
+		limit, err := i.limiter.Limit(ctx, key)
+		return limit, 0, err
+	}
+	decision, err := dl.Allow(ctx, key)
+	if err != nil {
+		return false, 0, err
+	}
+	return !decision.Allowed, decision.RetryAfter, nil
+}
+
+// limitedError 触发限流时返回的错误，retryAfter > 0时，在status的details中附带RetryInfo，
+// 客户端可以通过status.FromError(err).Details()获取需要等待的时间
+func limitedError(retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "触发限流")
+	if retryAfter <= 0 {
+		return st.Err()
+	}
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
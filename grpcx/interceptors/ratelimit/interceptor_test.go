@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"GoToolkit/limitx"
+	"context"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// decisionLimiter 返回固定决策的限流器
+type decisionLimiter struct {
+	decision limitx.Decision
+	err      error
+}
+
+func (d decisionLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return !d.decision.Allowed, d.err
+}
+
+func (d decisionLimiter) Allow(ctx context.Context, key string) (limitx.Decision, error) {
+	return d.decision, d.err
+}
+
+// plainLimiter 只实现了limitx.Limiter的限流器
+type plainLimiter bool
+
+func (p plainLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return bool(p), nil
+}
+
+// retryDelay 从status的details中取出RetryInfo，没有RetryInfo时返回-1
+func retryDelay(err error) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if retry, ok := detail.(*errdetails.RetryInfo); ok {
+			return retry.RetryDelay.AsDuration()
+		}
+	}
+	return -1
+}
+
+func TestInterceptor_RetryInfo(t *testing.T) {
+	ok := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	testCases := []struct {
+		name      string
+		limiter   limitx.Limiter
+		wantCode  codes.Code
+		wantDelay time.Duration // -1表示没有RetryInfo
+	}{
+		{
+			name:      "放行",
+			limiter:   decisionLimiter{decision: limitx.Decision{Allowed: true, Limit: 10, Remaining: 9}},
+			wantCode:  codes.OK,
+			wantDelay: -1,
+		},
+		{
+			name:      "限流，附带需要等待的时间",
+			limiter:   decisionLimiter{decision: limitx.Decision{Limit: 10, RetryAfter: 1500 * time.Millisecond}},
+			wantCode:  codes.ResourceExhausted,
+			wantDelay: 1500 * time.Millisecond,
+		},
+		{
+			name:      "限流器不返回决策",
+			limiter:   plainLimiter(true),
+			wantCode:  codes.ResourceExhausted,
+			wantDelay: -1,
+		},
+		{
+			name:      "限流器执行失败",
+			limiter:   decisionLimiter{err: errors.New("redis不可用")},
+			wantCode:  codes.ResourceExhausted,
+			wantDelay: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			i := NewInterceptor(tc.limiter, "test", nopLogger{}, "user.v1.UserService")
+			info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/Login"}
+			interceptors := map[string]grpc.UnaryServerInterceptor{
+				"整个服务": i.BuildServerInterceptor(),
+				"某个服务": i.BuildServerInterceptorService(),
+			}
+			for name, interceptor := range interceptors {
+				_, err := interceptor(context.Background(), nil, info, ok)
+				if code := status.Code(err); code != tc.wantCode {
+					t.Fatalf("%s，want code=%v，got err=%v", name, tc.wantCode, err)
+				}
+				if delay := retryDelay(err); delay != tc.wantDelay {
+					t.Fatalf("%s，want delay=%v，got=%v", name, tc.wantDelay, delay)
+				}
+			}
+		})
+	}
+}
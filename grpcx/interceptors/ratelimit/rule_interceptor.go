@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"net"
+	"time"
+)
+
+// KeyExtractor 从gRPC请求中提取限流对象，返回空字符串表示这条规则不限流
+type KeyExtractor func(ctx context.Context, info *grpc.UnaryServerInfo) string
+
+// RuleBinding 限流规则，以及按照这条规则限流的对象
+type RuleBinding struct {
+	Rule limitx.Rule
+	Key  KeyExtractor
+}
+
+// RuleInterceptor 多维度限流拦截器，一个请求同时按照多条规则限流（用户、IP、方法、全局）
+type RuleInterceptor struct {
+	limiter *limitx.RedisCompositeLimiter
+	global  []RuleBinding            // 所有方法都生效的规则
+	methods map[string][]RuleBinding // 按照gRPC的完整方法名声明的规则
+	logger  loggerx.Logger
+}
+
+func NewRuleInterceptor(limiter *limitx.RedisCompositeLimiter,
+	logger loggerx.Logger) *RuleInterceptor {
+	return &RuleInterceptor{
+		limiter: limiter,
+		methods: make(map[string][]RuleBinding),
+		logger:  logger,
+	}
+}
+
+// Global 声明所有方法都生效的规则
+func (i *RuleInterceptor) Global(bindings ...RuleBinding) *RuleInterceptor {
+	i.global = append(i.global, bindings...)
+	return i
+}
+
+// Method 声明某个方法的规则
+//
+//	fullMethod gRPC的完整方法名，比如"/user.v1.UserService/Login"
+func (i *RuleInterceptor) Method(fullMethod string, bindings ...RuleBinding) *RuleInterceptor {
+	i.methods[fullMethod] = append(i.methods[fullMethod], bindings...)
+	return i
+}
+
+// BuildServerInterceptor 对服务端限流
+func (i *RuleInterceptor) BuildServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (resp any, err error) {
+		hits := i.hits(ctx, info)
+		decision, err := i.limiter.Check(ctx, hits)
+		if err != nil {
+			// 保守法，拒绝请求
+			i.logger.Error("限流器执行失败", loggerx.Error(err),
+				loggerx.String("method:", info.FullMethod))
+			return nil, status.Errorf(codes.ResourceExhausted, "触发限流")
+		}
+		if !decision.Allowed {
+			i.logger.Warn("触发限流，可能有人在攻击你的系统",
+				loggerx.String("method:", info.FullMethod),
+				loggerx.String("rule", decision.Rule))
+			return nil, ruleLimitedError(decision.Rule, decision.RetryAfter)
+		}
+		// 执行下一个拦截器，或者是真实的业务代码
+		return handler(ctx, req)
+	}
+}
+
+// hits 当前请求命中的规则
+func (i *RuleInterceptor) hits(ctx context.Context, info *grpc.UnaryServerInfo) []limitx.Hit {
+	bindings := i.methods[info.FullMethod]
+	hits := make([]limitx.Hit, 0, len(i.global)+len(bindings))
+	for _, bs := range [][]RuleBinding{i.global, bindings} {
+		for _, b := range bs {
+			key := b.Key(ctx, info)
+			if key == "" {
+				continue
+			}
+			hits = append(hits, limitx.Hit{Rule: b.Rule, Key: key})
+		}
+	}
+	return hits
+}
+
+// ruleLimitedError 触发限流时返回的错误，在status的details中附带触发限流的规则和RetryInfo
+func ruleLimitedError(rule string, retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "触发限流："+rule)
+	detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason:   "RATE_LIMITED",
+			Metadata: map[string]string{"rule": rule},
+		},
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(retryAfter),
+		})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// KeyByPeerIP 按照客户端IP限流
+func KeyByPeerIP(prefix string) KeyExtractor {
+	return func(ctx context.Context, info *grpc.UnaryServerInfo) string {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return ""
+		}
+		ip := p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		return prefix + ":ip:" + ip
+	}
+}
+
+// KeyByMetadata 按照metadata中的值限流，比如客户端传入的用户id，metadata不存在时不限流
+func KeyByMetadata(prefix, name string) KeyExtractor {
+	return func(ctx context.Context, info *grpc.UnaryServerInfo) string {
+		vals := metadata.ValueFromIncomingContext(ctx, name)
+		if len(vals) == 0 || vals[0] == "" {
+			return ""
+		}
+		return prefix + ":" + name + ":" + vals[0]
+	}
+}
+
+// KeyByFullMethod 按照方法限流
+func KeyByFullMethod(prefix string) KeyExtractor {
+	return func(ctx context.Context, info *grpc.UnaryServerInfo) string {
+		return prefix + ":method:" + info.FullMethod
+	}
+}
+
+// KeyConst 所有请求使用同一个限流对象，用来做全局限流
+func KeyConst(key string) KeyExtractor {
+	return func(ctx context.Context, info *grpc.UnaryServerInfo) string {
+		return key
+	}
+}
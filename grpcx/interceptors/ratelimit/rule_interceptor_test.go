@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// newRedisClient 连接本地的redis，redis不可用时跳过测试
+func newRedisClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis不可用，跳过测试，err=%v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...loggerx.Field) {}
+func (nopLogger) Info(string, ...loggerx.Field)  {}
+func (nopLogger) Warn(string, ...loggerx.Field)  {}
+func (nopLogger) Error(string, ...loggerx.Field) {}
+
+func TestRuleInterceptor(t *testing.T) {
+	client := newRedisClient(t)
+	// 每个测试使用不同的hash tag，规则的key在窗口结束后自动过期
+	limiter := limitx.NewRedisCompositeLimiter(client,
+		limitx.WithKeyPrefix("limitx:test"), limitx.WithHashTag(uuid.NewString()))
+	const login = "/user.v1.UserService/Login"
+	// 全局规则和方法规则使用同一个限流对象（按照用户id限流）
+	interceptor := NewRuleInterceptor(limiter, nopLogger{}).
+		Global(RuleBinding{
+			Rule: limitx.Rule{Name: "user", Interval: time.Minute, Rate: 3},
+			Key:  KeyByMetadata("test", "uid"),
+		}).
+		Method(login, RuleBinding{
+			Rule: limitx.Rule{Name: "login", Interval: time.Minute, Rate: 1},
+			Key:  KeyByMetadata("test", "uid"),
+		}).
+		BuildServerInterceptor()
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	call := func(method, uid string) error {
+		ctx := context.Background()
+		if uid != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("uid", uid))
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	testCases := []struct {
+		name     string
+		method   string
+		uid      string
+		wantRule string // 触发限流的规则，空字符串表示放行
+	}{
+		{name: "登录", method: login, uid: "1"},
+		{name: "触发方法规则", method: login, uid: "1", wantRule: "login"},
+		// 方法规则不影响其他方法，被限流的请求不占用全局规则的配额
+		{name: "其他方法", method: "/user.v1.UserService/Profile", uid: "1"},
+		{name: "其他方法", method: "/user.v1.UserService/Profile", uid: "1"},
+		{name: "触发全局规则", method: "/user.v1.UserService/Profile", uid: "1", wantRule: "user"},
+		{name: "其他用户", method: login, uid: "2"},
+		// metadata不存在时，规则不限流
+		{name: "没有用户id", method: login},
+	}
+	for _, tc := range testCases {
+		err := call(tc.method, tc.uid)
+		if tc.wantRule == "" {
+			if err != nil {
+				t.Fatalf("%s，应该放行，err=%v", tc.name, err)
+			}
+			continue
+		}
+		st := status.Convert(err)
+		if st.Code() != codes.ResourceExhausted {
+			t.Fatalf("%s，应该触发限流，err=%v", tc.name, err)
+		}
+		var rule string
+		var retry *errdetails.RetryInfo
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.ErrorInfo:
+				rule = d.Metadata["rule"]
+			case *errdetails.RetryInfo:
+				retry = d
+			}
+		}
+		if rule != tc.wantRule || retry == nil || retry.RetryDelay.AsDuration() <= 0 {
+			t.Fatalf("%s，want rule=%q，got rule=%q，retry=%v", tc.name, tc.wantRule, rule, retry)
+		}
+	}
+}
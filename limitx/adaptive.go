@@ -0,0 +1,168 @@
+package limitx
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveLimiter 自适应限流（参考BBR算法），不需要手动设置阈值
+//
+//	统计最近一段时间内，每个桶完成的最大请求数maxPass和最小的平均响应时间minRT，
+//	maxPass * minRT 近似等于实例的最大处理能力（最大并发数），
+//	只有CPU使用率超过阈值（实例饱和）并且正在处理的请求数超过最大并发数时，才会拒绝请求
+//
+//	使用请求的ctx统计请求的结束时间，ctx被取消（gRPC、HTTP请求结束时会取消ctx）时视为请求结束，
+//	所以可以直接传给ratelimit.NewInterceptor使用；key会被忽略，限制的是整个实例
+type AdaptiveLimiter struct {
+	cpuThreshold float64        // CPU使用率的阈值（0~1）
+	cpuUsage     func() float64 // 获取CPU使用率
+	coolDown     time.Duration  // 触发限流后的冷却时间，冷却期内即使CPU回落，也会继续检查并发数
+
+	inFlight atomic.Int64 // 正在处理的请求数
+	prevDrop atomic.Int64 // 上一次触发限流的时间（纳秒）
+
+	mu         sync.Mutex
+	buckets    []adaptiveBucket
+	bucketSize time.Duration // 每个桶的时间跨度
+}
+
+// adaptiveBucket 一个时间桶内完成的请求数和响应时间总和
+type adaptiveBucket struct {
+	start int64 // 桶的起始时间（纳秒）
+	pass  int64 // 完成的请求数
+	rt    int64 // 响应时间的总和（纳秒）
+}
+
+// AdaptiveOption 自适应限流器的配置选项
+type AdaptiveOption func(*AdaptiveLimiter)
+
+// WithCPUThreshold 设置CPU使用率的阈值（0~1），默认0.8
+func WithCPUThreshold(threshold float64) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		l.cpuThreshold = threshold
+	}
+}
+
+// WithCPUUsage 设置获取CPU使用率（0~1）的方法，默认读取/proc/stat
+func WithCPUUsage(fn func() float64) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		l.cpuUsage = fn
+	}
+}
+
+// WithCoolDown 设置触发限流后的冷却时间，默认1s
+func WithCoolDown(d time.Duration) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		l.coolDown = d
+	}
+}
+
+// WithWindow 设置统计窗口的大小和桶的数量，默认10s、100个桶
+//
+//	buckets<=0，或者每个桶的时间跨度不足1ns时，配置不合法，使用默认值
+func WithWindow(window time.Duration, buckets int) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		if buckets <= 0 || window/time.Duration(buckets) <= 0 {
+			return
+		}
+		l.buckets = make([]adaptiveBucket, buckets)
+		l.bucketSize = window / time.Duration(buckets)
+	}
+}
+
+func NewAdaptiveLimiter(opts ...AdaptiveOption) *AdaptiveLimiter {
+	l := &AdaptiveLimiter{
+		cpuThreshold: 0.8,
+		cpuUsage:     systemCPUUsage,
+		coolDown:     time.Second,
+		buckets:      make([]adaptiveBucket, 100),
+		bucketSize:   100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *AdaptiveLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	if l.shouldDrop(time.Now()) {
+		return true, nil
+	}
+	start := time.Now()
+	l.inFlight.Add(1)
+	// ctx永远不会被取消时，无法统计请求的结束时间
+	if ctx.Done() == nil {
+		l.inFlight.Add(-1)
+		return false, nil
+	}
+	context.AfterFunc(ctx, func() {
+		l.inFlight.Add(-1)
+		l.record(time.Now(), time.Since(start))
+	})
+	return false, nil
+}
+
+// InFlight 正在处理的请求数
+func (l *AdaptiveLimiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// shouldDrop 判断是否需要拒绝请求
+func (l *AdaptiveLimiter) shouldDrop(now time.Time) bool {
+	if l.cpuUsage() < l.cpuThreshold {
+		// CPU没有饱和，不在冷却期内直接放行
+		prevDrop := l.prevDrop.Load()
+		if prevDrop == 0 || now.UnixNano()-prevDrop > l.coolDown.Nanoseconds() {
+			return false
+		}
+		return l.inFlight.Load() > l.maxInFlight(now)
+	}
+	// CPU饱和，正在处理的请求数超过最大并发数时拒绝请求
+	if l.inFlight.Load() <= l.maxInFlight(now) {
+		return false
+	}
+	l.prevDrop.Store(now.UnixNano())
+	return true
+}
+
+// maxInFlight 最大并发数 == 每秒最多完成的请求数 * 最小的平均响应时间（秒）
+func (l *AdaptiveLimiter) maxInFlight(now time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := now.UnixNano() / l.bucketSize.Nanoseconds() * l.bucketSize.Nanoseconds()
+	oldest := current - int64(len(l.buckets))*l.bucketSize.Nanoseconds()
+	var maxPass int64
+	minRT := math.MaxFloat64
+	for _, b := range l.buckets {
+		// 跳过过期的桶和当前还没有结束的桶
+		if b.pass == 0 || b.start <= oldest || b.start >= current {
+			continue
+		}
+		maxPass = max(maxPass, b.pass)
+		minRT = min(minRT, float64(b.rt)/float64(b.pass))
+	}
+	// 还没有统计数据，不限制并发数
+	if maxPass == 0 {
+		return math.MaxInt64
+	}
+	perSecond := float64(maxPass) * float64(time.Second) / float64(l.bucketSize)
+	return int64(math.Ceil(perSecond * minRT / float64(time.Second)))
+}
+
+// record 记录一个完成的请求
+func (l *AdaptiveLimiter) record(now time.Time, rt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	size := l.bucketSize.Nanoseconds()
+	start := now.UnixNano() / size * size
+	b := &l.buckets[start/size%int64(len(l.buckets))]
+	// 桶已经过期，重新统计
+	if b.start != start {
+		*b = adaptiveBucket{start: start}
+	}
+	b.pass++
+	b.rt += rt.Nanoseconds()
+}
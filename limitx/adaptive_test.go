@@ -0,0 +1,75 @@
+package limitx
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	var cpu atomic.Value
+	cpu.Store(0.9)
+	l := NewAdaptiveLimiter(
+		WithCPUUsage(func() float64 { return cpu.Load().(float64) }),
+		WithWindow(time.Second, 10),
+		WithCoolDown(50*time.Millisecond))
+
+	// 还没有统计数据时，不会拒绝请求
+	// 每个桶完成10个请求，每个请求耗时约10ms，最大并发数约为1
+	for i := 0; i < 30; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		limit, err := l.Limit(ctx, "")
+		if err != nil || limit {
+			t.Fatalf("没有统计数据时，不应该限流，limit=%v，err=%v", limit, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}
+	// AfterFunc在单独的协程中执行
+	time.Sleep(10 * time.Millisecond)
+	if l.InFlight() != 0 {
+		t.Fatalf("请求结束后，并发数应该为0，got=%d", l.InFlight())
+	}
+
+	// CPU饱和，并发数超过最大并发数时，拒绝请求
+	var cancels []context.CancelFunc
+	limited := false
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels = append(cancels, cancel)
+		limit, _ := l.Limit(ctx, "")
+		if limit {
+			limited = true
+			break
+		}
+	}
+	if !limited {
+		t.Fatal("CPU饱和并且并发数过高，应该限流")
+	}
+
+	// CPU回落，冷却期过后，不再限流
+	cpu.Store(0.1)
+	time.Sleep(60 * time.Millisecond)
+	if limit, _ := l.Limit(context.Background(), ""); limit {
+		t.Fatal("CPU回落并且冷却期已过，不应该限流")
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// TestWithWindow_Invalid 桶的数量不合法时使用默认值，不会在请求时panic
+func TestWithWindow_Invalid(t *testing.T) {
+	for _, buckets := range []int{0, -1} {
+		l := NewAdaptiveLimiter(WithCPUUsage(func() float64 { return 1 }), WithWindow(time.Second, buckets))
+		if len(l.buckets) != 100 || l.bucketSize != 100*time.Millisecond {
+			t.Fatalf("桶的数量不合法，应该使用默认值，buckets=%d", buckets)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if _, err := l.Limit(ctx, ""); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+	}
+}
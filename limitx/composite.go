@@ -0,0 +1,85 @@
+package limitx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+//go:embed lua/composite_window.lua
+var luaCompositeWindow string
+
+var compositeWindowScript = redis.NewScript(luaCompositeWindow)
+
+// Rule 滑动窗口限流规则，Interval内允许Rate个请求
+type Rule struct {
+	Name     string        // 规则名称，触发限流时返回，比如"user"、"ip"、"global"
+	Interval time.Duration // 滑动窗口的大小
+	Rate     int           // 阈值，允许的请求数量
+}
+
+// Hit 一个请求命中的规则，以及按照这条规则限流的对象
+type Hit struct {
+	Rule Rule
+	Key  string // 限流对象，比如"user:123"、"ip:127.0.0.1"
+}
+
+// ruleKey 规则在redis中的key，包含规则的名称和窗口大小
+//
+//	不同的规则可能使用同一个限流对象（比如"user 10/s"和"user 1000/h"都按照用户id限流），
+//	如果共用一个有序集合，窗口小的规则会删除窗口大的规则的请求记录，窗口大的规则就失效了
+func (h Hit) ruleKey() string {
+	return h.Rule.Name + ":" + strconv.FormatInt(h.Rule.Interval.Milliseconds(), 10) + ":" + h.Key
+}
+
+// CompositeDecision 组合限流的决策
+type CompositeDecision struct {
+	Allowed    bool          // true表示放行，false表示限流
+	Rule       string        // 触发限流的规则名称，放行时为空
+	RetryAfter time.Duration // 触发限流的规则，距离下一个配额可用还需要等待的时间
+}
+
+// RedisCompositeLimiter redis组合限流，一个请求同时按照多条规则（用户、IP、方法、全局）限流
+//
+//	一次lua调用检查请求命中的所有规则，任意一条规则超出阈值就限流，并返回触发限流的规则
+//	使用redis集群时，必须通过WithHashTag设置hash tag，保证所有规则的key在同一个slot
+type RedisCompositeLimiter struct {
+	cmd  redis.Cmdable // redis客户端
+	opts redisOptions
+}
+
+func NewRedisCompositeLimiter(cmd redis.Cmdable, opts ...RedisOption) *RedisCompositeLimiter {
+	return &RedisCompositeLimiter{
+		cmd:  cmd,
+		opts: newRedisOptions(opts),
+	}
+}
+
+// Check 检查一个请求命中的所有规则
+func (r *RedisCompositeLimiter) Check(ctx context.Context, hits []Hit) (CompositeDecision, error) {
+	if len(hits) == 0 {
+		return CompositeDecision{Allowed: true}, nil
+	}
+	keys := make([]string, 0, len(hits))
+	// ARGV数组：当前时间，请求的唯一标识，每条规则的窗口大小和阈值
+	args := make([]any, 0, 2+2*len(hits))
+	args = append(args, time.Now().UnixMilli(), uuid.NewString())
+	for _, hit := range hits {
+		keys = append(keys, r.opts.key(hit.ruleKey()))
+		args = append(args, hit.Rule.Interval.Milliseconds(), hit.Rule.Rate)
+	}
+	res, err := compositeWindowScript.Run(ctx, r.cmd, keys, args...).Int64Slice()
+	if err != nil {
+		return CompositeDecision{}, err
+	}
+	if res[0] == 0 {
+		return CompositeDecision{Allowed: true}, nil
+	}
+	return CompositeDecision{
+		Rule:       hits[res[0]-1].Rule.Name,
+		RetryAfter: time.Duration(res[1]) * time.Millisecond,
+	}, nil
+}
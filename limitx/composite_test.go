@@ -0,0 +1,80 @@
+package limitx
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// newCompositeHits 创建使用同一个限流对象的多条规则，测试结束后删除规则的key
+func newCompositeHits(t *testing.T, l *RedisCompositeLimiter, key string, rules ...Rule) []Hit {
+	hits := make([]Hit, 0, len(rules))
+	for _, rule := range rules {
+		hits = append(hits, Hit{Rule: rule, Key: key})
+	}
+	t.Cleanup(func() {
+		for _, hit := range hits {
+			l.cmd.Del(context.Background(), l.opts.key(hit.ruleKey()))
+		}
+	})
+	return hits
+}
+
+func TestRedisCompositeLimiter(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	l := NewRedisCompositeLimiter(client, WithKeyPrefix("limitx:test"))
+	uid := uuid.NewString()
+	user := Rule{Name: "user", Interval: time.Minute, Rate: 2}
+	ip := Rule{Name: "ip", Interval: time.Minute, Rate: 3}
+	hits := func(uid string) []Hit {
+		return append(newCompositeHits(t, l, "user:"+uid, user), newCompositeHits(t, l, "ip:"+uid, ip)...)
+	}
+	for i := 0; i < 2; i++ {
+		d, err := l.Check(ctx, hits(uid))
+		if err != nil || !d.Allowed {
+			t.Fatalf("第%d个请求应该放行，decision=%+v，err=%v", i+1, d, err)
+		}
+	}
+	// user规则超出阈值，本次请求不会记录到ip规则中
+	for i := 0; i < 3; i++ {
+		d, err := l.Check(ctx, hits(uid))
+		if err != nil || d.Allowed || d.Rule != "user" || d.RetryAfter <= 0 {
+			t.Fatalf("应该触发user规则，decision=%+v，err=%v", d, err)
+		}
+	}
+	d, err := l.Check(ctx, newCompositeHits(t, l, "ip:"+uid, ip))
+	if err != nil || !d.Allowed {
+		t.Fatalf("被限流的请求不应该占用ip规则的配额，decision=%+v，err=%v", d, err)
+	}
+	// 没有命中任何规则，直接放行
+	if d, err = l.Check(ctx, nil); err != nil || !d.Allowed {
+		t.Fatalf("没有命中规则，应该放行，decision=%+v，err=%v", d, err)
+	}
+}
+
+// TestRedisCompositeLimiter_SharedKey 多条规则使用同一个限流对象时，各自记录请求，窗口小的规则不会影响窗口大的规则
+func TestRedisCompositeLimiter_SharedKey(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	l := NewRedisCompositeLimiter(client, WithKeyPrefix("limitx:test"))
+	hits := newCompositeHits(t, l, "user:"+uuid.NewString(),
+		Rule{Name: "user", Interval: 100 * time.Millisecond, Rate: 2},
+		Rule{Name: "user", Interval: time.Hour, Rate: 3})
+	check := func(wantAllowed bool, wantRule string) {
+		t.Helper()
+		d, err := l.Check(ctx, hits)
+		if err != nil || d.Allowed != wantAllowed || d.Rule != wantRule {
+			t.Fatalf("want allowed=%v，rule=%q，decision=%+v，err=%v", wantAllowed, wantRule, d, err)
+		}
+	}
+	check(true, "")
+	check(true, "")
+	check(false, "user")
+	// 小窗口的请求移出窗口，大窗口内还有2个请求，只剩1个配额
+	time.Sleep(150 * time.Millisecond)
+	check(true, "")
+	time.Sleep(150 * time.Millisecond)
+	check(false, "user")
+}
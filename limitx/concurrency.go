@@ -0,0 +1,142 @@
+package limitx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+var (
+	//go:embed lua/concurrency_acquire.lua
+	luaConcurrencyAcquire string
+	//go:embed lua/concurrency_renew.lua
+	luaConcurrencyRenew string
+
+	concurrencyAcquireScript = redis.NewScript(luaConcurrencyAcquire)
+	concurrencyRenewScript   = redis.NewScript(luaConcurrencyRenew)
+)
+
+// ConcurrencyLimiter 并发限流器，限制同一个key同时在处理的请求数量
+//
+//	适合保护耗时长的下游调用，比如大文件上传，这类请求数量不多，但是每个请求占用资源的时间很长
+type ConcurrencyLimiter interface {
+	// Acquire 获取一个并发槽位
+	// bool 返回true表示限流（没有空闲的槽位），返回false表示获取成功，处理结束后必须调用release释放槽位
+	Acquire(ctx context.Context, key string) (release func(), limited bool, err error)
+}
+
+// LocalConcurrencyLimiter 本地并发限流，只对当前实例生效
+type LocalConcurrencyLimiter struct {
+	max    int // 每个key的最大并发数
+	mu     sync.Mutex
+	counts map[string]int // 每个key正在处理的请求数量，为0时删除，内存占用和正在处理的请求数量有关
+}
+
+func NewLocalConcurrencyLimiter(maxConcurrency int) *LocalConcurrencyLimiter {
+	return &LocalConcurrencyLimiter{
+		max:    maxConcurrency,
+		counts: make(map[string]int),
+	}
+}
+
+func (l *LocalConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[key] >= l.max {
+		return nil, true, nil
+	}
+	l.counts[key]++
+	var once sync.Once
+	return func() {
+		// 多次调用release只释放一次
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.counts[key]--
+			if l.counts[key] <= 0 {
+				delete(l.counts, key)
+			}
+		})
+	}, false, nil
+}
+
+// minConcurrencyTTL 租约的最小有效期，lua脚本使用毫秒计时，并且需要每隔ttl/3续约一次
+const minConcurrencyTTL = 3 * time.Millisecond
+
+// RedisConcurrencyLimiter redis并发限流，限制整个集群
+//
+//	每个槽位是一个有过期时间的租约，持有者崩溃没有释放槽位时，租约过期后槽位会被自动回收；
+//	持有槽位期间，后台每隔ttl/3续约一次，处理时间超过ttl的请求也不会丢失槽位
+type RedisConcurrencyLimiter struct {
+	cmd  redis.Cmdable // redis客户端
+	max  int           // 每个key的最大并发数
+	ttl  time.Duration // 租约的有效期
+	opts redisOptions
+}
+
+func NewRedisConcurrencyLimiter(cmd redis.Cmdable, maxConcurrency int,
+	ttl time.Duration, opts ...RedisOption) *RedisConcurrencyLimiter {
+	if maxConcurrency <= 0 {
+		panic("limitx: 最大并发数必须大于0")
+	}
+	if ttl < minConcurrencyTTL {
+		panic("limitx: 租约的有效期不能小于" + minConcurrencyTTL.String())
+	}
+	return &RedisConcurrencyLimiter{
+		cmd:  cmd,
+		max:  maxConcurrency,
+		ttl:  ttl,
+		opts: newRedisOptions(opts),
+	}
+}
+
+func (r *RedisConcurrencyLimiter) Acquire(ctx context.Context, key string) (func(), bool, error) {
+	key = r.opts.key(key)
+	// 租约id
+	id := uuid.NewString()
+	ok, err := concurrencyAcquireScript.Run(ctx, r.cmd, []string{key},
+		time.Now().UnixMilli(), r.ttl.Milliseconds(), r.max, id).Bool()
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, true, nil
+	}
+	// 后台续约，直到释放槽位
+	stop := make(chan struct{})
+	go r.renew(key, id, stop)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			// 请求的ctx可能已经被取消了，使用新的ctx释放槽位
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			// 释放失败也没有关系，租约过期后槽位会被自动回收
+			r.cmd.ZRem(ctx, key, id)
+		})
+	}, false, nil
+}
+
+// renew 每隔ttl/3续约一次，租约已经过期被回收时停止续约
+func (r *RedisConcurrencyLimiter) renew(key, id string, stop chan struct{}) {
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			ok, err := concurrencyRenewScript.Run(ctx, r.cmd, []string{key},
+				time.Now().UnixMilli(), r.ttl.Milliseconds(), id).Bool()
+			cancel()
+			if err == nil && !ok {
+				return
+			}
+		}
+	}
+}
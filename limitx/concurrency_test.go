@@ -0,0 +1,132 @@
+package limitx
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiters(t *testing.T) {
+	key := uuid.NewString()
+	limiters := map[string]func(t *testing.T) ConcurrencyLimiter{
+		"本地": func(t *testing.T) ConcurrencyLimiter {
+			return NewLocalConcurrencyLimiter(2)
+		},
+		"redis": func(t *testing.T) ConcurrencyLimiter {
+			client := newRedisClient(t)
+			t.Cleanup(func() {
+				client.Del(context.Background(), "limitx:test{"+key+"}", "limitx:test{"+key+":other}")
+			})
+			return NewRedisConcurrencyLimiter(client, 2, time.Minute, WithKeyPrefix("limitx:test"))
+		},
+	}
+	for name, newLimiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			l := newLimiter(t)
+			ctx := context.Background()
+			var releases []func()
+			for i := 0; i < 2; i++ {
+				release, limited, err := l.Acquire(ctx, key)
+				if err != nil || limited {
+					t.Fatalf("第%d个请求应该获取到槽位，limited=%v，err=%v", i+1, limited, err)
+				}
+				releases = append(releases, release)
+			}
+			if _, limited, err := l.Acquire(ctx, key); err != nil || !limited {
+				t.Fatalf("超出最大并发数应该限流，limited=%v，err=%v", limited, err)
+			}
+			// 其他key不受影响
+			release, limited, err := l.Acquire(ctx, key+":other")
+			if err != nil || limited {
+				t.Fatalf("其他key应该获取到槽位，limited=%v，err=%v", limited, err)
+			}
+			release()
+			// 多次调用release只释放一次
+			releases[0]()
+			releases[0]()
+			if _, limited, err = l.Acquire(ctx, key); err != nil || limited {
+				t.Fatalf("释放槽位后应该获取到槽位，limited=%v，err=%v", limited, err)
+			}
+			if _, limited, err = l.Acquire(ctx, key); err != nil || !limited {
+				t.Fatalf("重复释放不应该多空出槽位，limited=%v，err=%v", limited, err)
+			}
+			releases[1]()
+		})
+	}
+}
+
+// TestRedisConcurrencyLimiter_LeaseExpired 持有者崩溃没有释放槽位时，租约过期后槽位被回收
+func TestRedisConcurrencyLimiter_LeaseExpired(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const ttl = 100 * time.Millisecond
+	l := NewRedisConcurrencyLimiter(client, 1, ttl)
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	// 模拟崩溃的持有者：获取槽位之后，既不续约也不释放
+	ok, err := concurrencyAcquireScript.Run(ctx, client, []string{key},
+		time.Now().UnixMilli(), ttl.Milliseconds(), 1, uuid.NewString()).Bool()
+	if err != nil || !ok {
+		t.Fatalf("应该获取到槽位，ok=%v，err=%v", ok, err)
+	}
+	if _, limited, err := l.Acquire(ctx, key); err != nil || !limited {
+		t.Fatalf("租约没有过期，应该限流，limited=%v，err=%v", limited, err)
+	}
+	time.Sleep(ttl + 20*time.Millisecond)
+	release, limited, err := l.Acquire(ctx, key)
+	if err != nil || limited {
+		t.Fatalf("租约过期后槽位应该被回收，limited=%v，err=%v", limited, err)
+	}
+	release()
+}
+
+// TestRedisConcurrencyLimiter_Renew 处理时间超过ttl的请求，后台续约，不会丢失槽位
+func TestRedisConcurrencyLimiter_Renew(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	const ttl = 90 * time.Millisecond
+	l := NewRedisConcurrencyLimiter(client, 1, ttl)
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	release, limited, err := l.Acquire(ctx, key)
+	if err != nil || limited {
+		t.Fatalf("应该获取到槽位，limited=%v，err=%v", limited, err)
+	}
+	time.Sleep(3 * ttl)
+	if _, limited, err = l.Acquire(ctx, key); err != nil || !limited {
+		t.Fatalf("续约后槽位还在，应该限流，limited=%v，err=%v", limited, err)
+	}
+	release()
+	if release, limited, err = l.Acquire(ctx, key); err != nil || limited {
+		t.Fatalf("释放槽位后应该获取到槽位，limited=%v，err=%v", limited, err)
+	}
+	release()
+}
+
+// TestNewRedisConcurrencyLimiter_Invalid 参数不合法时，创建限流器直接panic，而不是在后台续约的协程中panic
+func TestNewRedisConcurrencyLimiter_Invalid(t *testing.T) {
+	testCases := []struct {
+		name           string
+		maxConcurrency int
+		ttl            time.Duration
+	}{
+		{name: "ttl为0", maxConcurrency: 1},
+		{name: "ttl太小", maxConcurrency: 1, ttl: time.Nanosecond},
+		{name: "最大并发数为0", ttl: time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("参数不合法，应该panic")
+				}
+			}()
+			NewRedisConcurrencyLimiter(nil, tc.maxConcurrency, tc.ttl)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package limitx
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	cpuSampleInterval = 250 * time.Millisecond // CPU使用率的采样间隔
+	cpuDecay          = 0.95                   // 滑动平均的衰减系数，越大越平滑
+)
+
+var (
+	cpuOnce  sync.Once
+	cpuUsage atomic.Uint64 // 滑动平均后的CPU使用率，math.Float64bits
+)
+
+// systemCPUUsage 当前机器的CPU使用率（0~1），第一次调用时启动后台采样
+//
+//	读取/proc/stat计算，只支持linux，其他系统始终返回0；
+//	容器内可以使用WithCPUUsage传入按照cgroup配额计算的使用率
+func systemCPUUsage() float64 {
+	cpuOnce.Do(func() {
+		go sampleCPU()
+	})
+	return math.Float64frombits(cpuUsage.Load())
+}
+
+// sampleCPU 定时采样CPU使用率，使用指数加权滑动平均，避免瞬时的抖动
+func sampleCPU() {
+	prevIdle, prevTotal, err := readProcStat()
+	if err != nil {
+		return
+	}
+	ticker := time.NewTicker(cpuSampleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		idle, total, err := readProcStat()
+		if err != nil || total <= prevTotal {
+			continue
+		}
+		usage := 1 - float64(idle-prevIdle)/float64(total-prevTotal)
+		prevIdle, prevTotal = idle, total
+		avg := math.Float64frombits(cpuUsage.Load())
+		cpuUsage.Store(math.Float64bits(avg*cpuDecay + usage*(1-cpuDecay)))
+	}
+}
+
+// readProcStat 读取/proc/stat的第一行，返回空闲时间和总时间
+//
+//	cpu  user nice system idle iowait irq softirq steal guest guest_nice
+func readProcStat() (idle, total uint64, err error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, errors.New("读取/proc/stat失败")
+	}
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, errors.New("/proc/stat格式错误")
+	}
+	for i, field := range fields[1:] {
+		val, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		// guest和guest_nice已经包含在user和nice中了
+		if i >= 8 {
+			break
+		}
+		total += val
+		// idle和iowait
+		if i == 3 || i == 4 {
+			idle += val
+		}
+	}
+	return idle, total, nil
+}
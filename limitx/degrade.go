@@ -0,0 +1,218 @@
+package limitx
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mode 降级限流器的工作模式
+type Mode int32
+
+const (
+	ModeRemote Mode = iota // 使用远程（redis）限流器，限制整个集群
+	ModeLocal              // 远程限流器故障，降级为本地限流器，只限制当前实例
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeRemote:
+		return "remote"
+	case ModeLocal:
+		return "local"
+	default:
+		return "unknown"
+	}
+}
+
+// DegradingLimiter 可降级的限流器
+//
+//	正常情况下使用远程限流器，远程限流器返回错误或超时，切换为本地限流器，
+//	同时在后台定时执行健康检查，连续成功successThreshold次后，切换回远程限流器
+//	避免redis抖动时，限流器返回错误，导致拦截器拒绝所有请求
+type DegradingLimiter struct {
+	remote      Limiter                     // 远程限流器，比如RedisSlidingWindowLimiter
+	local       Limiter                     // 本地限流器，阈值应该是当前实例分摊的阈值，参考InstanceShare
+	healthCheck func(context.Context) error // 健康检查，返回nil表示远程限流器恢复了
+
+	timeout          time.Duration // 调用远程限流器的超时时间
+	checkInterval    time.Duration // 健康检查的间隔
+	successThreshold int           // 健康检查连续成功多少次，才切换回远程限流器
+	onModeChange     func(from, to Mode)
+
+	mode     atomic.Int32
+	checking atomic.Bool // 是否正在执行健康检查
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// DegradeOption 降级限流器的配置选项
+type DegradeOption func(*DegradingLimiter)
+
+// WithRemoteTimeout 设置调用远程限流器的超时时间，超时视为远程限流器故障
+func WithRemoteTimeout(d time.Duration) DegradeOption {
+	return func(l *DegradingLimiter) {
+		l.timeout = d
+	}
+}
+
+// WithHealthCheckInterval 设置健康检查的间隔
+func WithHealthCheckInterval(d time.Duration) DegradeOption {
+	return func(l *DegradingLimiter) {
+		l.checkInterval = d
+	}
+}
+
+// WithSuccessThreshold 设置健康检查连续成功多少次，才切换回远程限流器
+func WithSuccessThreshold(n int) DegradeOption {
+	return func(l *DegradingLimiter) {
+		l.successThreshold = n
+	}
+}
+
+// WithModeChangeCallback 设置工作模式切换时的回调，可以用来记录日志或者上报监控指标
+func WithModeChangeCallback(fn func(from, to Mode)) DegradeOption {
+	return func(l *DegradingLimiter) {
+		l.onModeChange = fn
+	}
+}
+
+// NewDegradingLimiter 创建可降级的限流器
+//
+//	healthCheck 健康检查，一般使用PingHealthCheck(cmd)
+func NewDegradingLimiter(remote, local Limiter, healthCheck func(context.Context) error,
+	opts ...DegradeOption) *DegradingLimiter {
+	l := &DegradingLimiter{
+		remote:           remote,
+		local:            local,
+		healthCheck:      healthCheck,
+		timeout:          100 * time.Millisecond,
+		checkInterval:    time.Second,
+		successThreshold: 3,
+		stop:             make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// PingHealthCheck 使用PING命令检查redis是否恢复
+func PingHealthCheck(cmd redis.Cmdable) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return cmd.Ping(ctx).Err()
+	}
+}
+
+// InstanceShare 计算单个实例分摊的阈值，用来创建降级时使用的本地限流器
+//
+//	比如整个集群10s内允许1000个请求，一共部署了4个实例，每个实例10s内允许250个请求
+func InstanceShare(rate int, instances int) int {
+	if instances <= 1 {
+		return rate
+	}
+	return (rate + instances - 1) / instances
+}
+
+// Mode 当前的工作模式
+func (l *DegradingLimiter) Mode() Mode {
+	return Mode(l.mode.Load())
+}
+
+func (l *DegradingLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := l.Allow(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return !decision.Allowed, nil
+}
+
+// Allow 判断是否放行key的本次请求，被包装的限流器没有实现DecisionLimiter时，只有Allowed字段有效
+func (l *DegradingLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	if l.Mode() == ModeLocal {
+		return decide(ctx, l.local, key)
+	}
+	remoteCtx, cancel := context.WithTimeout(ctx, l.timeout)
+	decision, err := decide(remoteCtx, l.remote, key)
+	cancel()
+	if err == nil {
+		return decision, nil
+	}
+	// 调用方取消了请求，不是远程限流器的问题
+	if ctx.Err() != nil {
+		return Decision{}, err
+	}
+	// 远程限流器故障，降级为本地限流器
+	l.degrade()
+	return decide(ctx, l.local, key)
+}
+
+// Close 停止后台的健康检查
+func (l *DegradingLimiter) Close() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+// degrade 切换为本地限流器，并启动健康检查
+func (l *DegradingLimiter) degrade() {
+	if l.mode.CompareAndSwap(int32(ModeRemote), int32(ModeLocal)) {
+		l.notify(ModeRemote, ModeLocal)
+	}
+	// 只启动一个健康检查的协程
+	if l.checking.CompareAndSwap(false, true) {
+		go l.check()
+	}
+}
+
+// check 定时执行健康检查，连续成功successThreshold次后，切换回远程限流器
+func (l *DegradingLimiter) check() {
+	ticker := time.NewTicker(l.checkInterval)
+	defer ticker.Stop()
+	success := 0
+	for {
+		select {
+		case <-l.stop:
+			l.checking.Store(false)
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+			err := l.healthCheck(ctx)
+			cancel()
+			if err != nil {
+				success = 0
+				continue
+			}
+			success++
+			if success < l.successThreshold {
+				continue
+			}
+			// 先结束健康检查，再切换模式，切换后远程限流器再次故障时，可以重新启动健康检查
+			l.checking.Store(false)
+			if l.mode.CompareAndSwap(int32(ModeLocal), int32(ModeRemote)) {
+				l.notify(ModeLocal, ModeRemote)
+			}
+			return
+		}
+	}
+}
+
+func (l *DegradingLimiter) notify(from, to Mode) {
+	if l.onModeChange != nil {
+		l.onModeChange(from, to)
+	}
+}
+
+// decide 调用限流器，限流器没有实现DecisionLimiter时，使用Limit的结果构造Decision
+func decide(ctx context.Context, l Limiter, key string) (Decision, error) {
+	if dl, ok := l.(DecisionLimiter); ok {
+		return dl.Allow(ctx, key)
+	}
+	limit, err := l.Limit(ctx, key)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Allowed: !limit}, nil
+}
@@ -0,0 +1,73 @@
+package limitx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyLimiter 可以模拟故障的限流器
+type flakyLimiter struct {
+	broken atomic.Bool
+}
+
+func (f *flakyLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	if f.broken.Load() {
+		return false, errors.New("redis: connection refused")
+	}
+	return false, nil
+}
+
+func TestDegradingLimiter(t *testing.T) {
+	remote := &flakyLimiter{}
+	var mu sync.Mutex
+	var changes []Mode
+	l := NewDegradingLimiter(remote, NewLocalFixedWindowLimiter(time.Hour, 1),
+		func(ctx context.Context) error {
+			if remote.broken.Load() {
+				return errors.New("redis: connection refused")
+			}
+			return nil
+		},
+		WithHealthCheckInterval(5*time.Millisecond),
+		WithSuccessThreshold(2),
+		WithModeChangeCallback(func(from, to Mode) {
+			mu.Lock()
+			changes = append(changes, to)
+			mu.Unlock()
+		}))
+	defer l.Close()
+	ctx := context.Background()
+
+	// 远程限流器故障，降级为本地限流器，不返回错误
+	remote.broken.Store(true)
+	limit, err := l.Limit(ctx, "key")
+	if err != nil || limit {
+		t.Fatalf("降级后应该使用本地限流器放行，limit=%v，err=%v", limit, err)
+	}
+	if l.Mode() != ModeLocal {
+		t.Fatalf("want=%s，got=%s", ModeLocal, l.Mode())
+	}
+	limit, err = l.Limit(ctx, "key")
+	if err != nil || !limit {
+		t.Fatalf("本地限流器超出阈值，应该限流，limit=%v，err=%v", limit, err)
+	}
+
+	// 远程限流器恢复，健康检查通过后切换回远程限流器
+	remote.broken.Store(false)
+	deadline := time.Now().Add(time.Second)
+	for l.Mode() != ModeRemote && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if l.Mode() != ModeRemote {
+		t.Fatalf("want=%s，got=%s", ModeRemote, l.Mode())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 2 || changes[0] != ModeLocal || changes[1] != ModeRemote {
+		t.Fatalf("模式切换的回调错误，got=%v", changes)
+	}
+}
@@ -0,0 +1,83 @@
+package limitx
+
+import (
+	"context"
+	"time"
+)
+
+// LocalFixedWindowLimiter 本地固定窗口限流，不依赖redis，只对当前实例生效
+//
+//	时间按照interval切分成固定的窗口，每个窗口内最多允许rate个请求，每个key只保存一个计数器
+//	注意：两个相邻窗口的交界处，最多可能通过2*rate个请求
+type LocalFixedWindowLimiter struct {
+	interval time.Duration // 窗口的大小，时间间隔
+	rate     int           // 阈值，允许的请求数量
+	store    *localStore[fixedWindowState]
+}
+
+// fixedWindowState 当前窗口的起始时间和请求数量
+type fixedWindowState struct {
+	start int64 // 窗口的起始时间（纳秒）
+	count int
+}
+
+func NewLocalFixedWindowLimiter(interval time.Duration, rate int,
+	opts ...LocalOption) *LocalFixedWindowLimiter {
+	if interval <= 0 {
+		panic("limitx: 固定窗口的大小必须大于0")
+	}
+	return &LocalFixedWindowLimiter{
+		interval: interval,
+		rate:     rate,
+		store: newLocalStore(func() *fixedWindowState {
+			return &fixedWindowState{}
+		}, newLocalOptions(interval, opts)),
+	}
+}
+
+func (l *LocalFixedWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := l.Allow(ctx, key)
+	return !decision.Allowed, err
+}
+
+// Allow 判断是否放行key的本次请求，并返回剩余配额和重试时间
+func (l *LocalFixedWindowLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	now := time.Now()
+	decision := Decision{Limit: l.rate}
+	l.store.do(key, now, func(s *fixedWindowState) {
+		window := l.interval.Nanoseconds()
+		// 当前时间所在窗口的起始时间
+		start := now.UnixNano() - now.UnixNano()%window
+		if s.start != start {
+			// 进入了新的窗口，重新计数
+			s.start = start
+			s.count = 0
+		}
+		decision.ResetAt = time.Unix(0, start+window)
+		if s.count >= l.rate {
+			// 执行限流，等到下一个窗口
+			decision.RetryAfter = decision.ResetAt.Sub(now)
+			return
+		}
+		s.count++
+		decision.Allowed = true
+		decision.Remaining = l.rate - s.count
+	})
+	return decision, nil
+}
+
+// Reserve 预约一个配额，有配额时直接占用；没有配额时返回OK=false，Delay是建议的重试时间
+func (l *LocalFixedWindowLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	decision, err := l.Allow(ctx, key)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reserveByDecision(decision), nil
+}
+
+// Wait 阻塞直到获得配额，或者ctx结束
+func (l *LocalFixedWindowLimiter) Wait(ctx context.Context, key string) error {
+	return wait(ctx, func(ctx context.Context) (Reservation, error) {
+		return l.Reserve(ctx, key)
+	})
+}
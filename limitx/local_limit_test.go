@@ -0,0 +1,338 @@
+package limitx
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalLimiters(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limiter DecisionLimiter
+		// 连续请求时，允许通过的请求数量
+		wantAllowed int
+	}{
+		{
+			name:        "滑动窗口",
+			limiter:     NewLocalSlidingWindowLimiter(time.Minute, 5),
+			wantAllowed: 5,
+		},
+		{
+			name:        "固定窗口",
+			limiter:     NewLocalFixedWindowLimiter(time.Hour, 5),
+			wantAllowed: 5,
+		},
+		{
+			name:        "令牌桶",
+			limiter:     NewLocalTokenBucketLimiter(5, 0.001),
+			wantAllowed: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < tc.wantAllowed; i++ {
+				d, err := tc.limiter.Allow(ctx, "key")
+				if err != nil || !d.Allowed {
+					t.Fatalf("第%d个请求应该放行，decision=%+v，err=%v", i+1, d, err)
+				}
+				if d.Remaining != tc.wantAllowed-i-1 {
+					t.Fatalf("剩余配额错误，want=%d，got=%d", tc.wantAllowed-i-1, d.Remaining)
+				}
+			}
+			d, err := tc.limiter.Allow(ctx, "key")
+			if err != nil || d.Allowed {
+				t.Fatalf("超出阈值的请求应该限流，decision=%+v，err=%v", d, err)
+			}
+			if d.RetryAfter <= 0 {
+				t.Fatalf("限流时应该返回重试时间，decision=%+v", d)
+			}
+			// 不同的key互不影响
+			limit, err := tc.limiter.Limit(ctx, "other")
+			if err != nil || limit {
+				t.Fatalf("其他key不应该限流，limit=%v，err=%v", limit, err)
+			}
+		})
+	}
+}
+
+func TestLocalSlidingWindowLimiter_Slide(t *testing.T) {
+	l := NewLocalSlidingWindowLimiter(100*time.Millisecond, 2)
+	ctx := context.Background()
+	l.Limit(ctx, "key")
+	time.Sleep(60 * time.Millisecond)
+	l.Limit(ctx, "key")
+	if limit, _ := l.Limit(ctx, "key"); !limit {
+		t.Fatal("窗口内超出阈值，应该限流")
+	}
+	// 第一个请求移出窗口，空出一个配额
+	time.Sleep(50 * time.Millisecond)
+	if limit, _ := l.Limit(ctx, "key"); limit {
+		t.Fatal("第一个请求移出窗口后，应该放行")
+	}
+	if limit, _ := l.Limit(ctx, "key"); !limit {
+		t.Fatal("第二个请求还在窗口内，应该限流")
+	}
+}
+
+// TestSlidingWindowState_Push 环形队列按需扩容，扩容后保持请求的时间顺序，最多扩容到阈值
+func TestSlidingWindowState_Push(t *testing.T) {
+	s := &slidingWindowState{}
+	for i := int64(0); i < 6; i++ {
+		s.push(i, 20)
+	}
+	// 删除最早的4个请求，队列头部移动到中间，再写入的请求会绕回数组的开头
+	s.head, s.count = 4, 2
+	for i := int64(6); i < 20; i++ {
+		s.push(i, 20)
+	}
+	if len(s.times) != 16 {
+		t.Fatalf("环形队列应该扩容到16，got=%d", len(s.times))
+	}
+	for i := 0; i < s.count; i++ {
+		if got := s.times[(s.head+i)%len(s.times)]; got != int64(i+4) {
+			t.Fatalf("扩容后请求的顺序错误，index=%d，got=%d", i, got)
+		}
+	}
+	for i := int64(20); i < 30; i++ {
+		s.push(i, 20)
+	}
+	if len(s.times) != 20 {
+		t.Fatalf("环形队列最多扩容到阈值，got=%d", len(s.times))
+	}
+}
+
+// TestLocalSlidingWindowLimiter_Memory 阈值很大时，只保存实际的请求时间
+func TestLocalSlidingWindowLimiter_Memory(t *testing.T) {
+	l := NewLocalSlidingWindowLimiter(time.Minute, 100_000)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		l.Limit(ctx, "key")
+	}
+	l.store.do("key", time.Now(), func(s *slidingWindowState) {
+		if len(s.times) != slidingWindowMinCap {
+			t.Fatalf("不应该按照阈值预先分配内存，got=%d", len(s.times))
+		}
+	})
+	// 阈值为0时拒绝所有请求
+	zero := NewLocalSlidingWindowLimiter(time.Minute, 0)
+	if d, _ := zero.Allow(ctx, "key"); d.Allowed || d.RetryAfter != time.Minute {
+		t.Fatalf("阈值为0应该限流，decision=%+v", d)
+	}
+}
+
+// TestLocalLimiters_InvalidInterval 窗口大小不合法时，创建限流器直接panic，而不是在请求时panic
+func TestLocalLimiters_InvalidInterval(t *testing.T) {
+	constructors := map[string]func(){
+		"滑动窗口": func() { NewLocalSlidingWindowLimiter(0, 10) },
+		"固定窗口": func() { NewLocalFixedWindowLimiter(0, 10) },
+	}
+	for name, fn := range constructors {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("窗口大小为0，应该panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+// TestNewLocalTokenBucketLimiter_Invalid 容量或者速率不合法时，创建限流器直接panic，而不是计算空闲时间时溢出
+func TestNewLocalTokenBucketLimiter_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		capacity int
+		rate     float64
+	}{
+		{name: "速率为0", capacity: 5, rate: 0},
+		{name: "速率为负数", capacity: 5, rate: -1},
+		{name: "容量为0", capacity: 0, rate: 10},
+		{name: "容量为负数", capacity: -1, rate: 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("参数不合法，应该panic，capacity=%d，rate=%v", tc.capacity, tc.rate)
+				}
+			}()
+			NewLocalTokenBucketLimiter(tc.capacity, tc.rate)
+		})
+	}
+}
+
+func TestLocalTokenBucketLimiter_Refill(t *testing.T) {
+	l := NewLocalTokenBucketLimiter(2, 20)
+	ctx := context.Background()
+	l.Limit(ctx, "key")
+	l.Limit(ctx, "key")
+	d, _ := l.Allow(ctx, "key")
+	if d.Allowed {
+		t.Fatal("令牌用完，应该限流")
+	}
+	time.Sleep(d.RetryAfter)
+	if limit, _ := l.Limit(ctx, "key"); limit {
+		t.Fatal("等待RetryAfter之后，应该放行")
+	}
+}
+
+// TestLocalLimiters_Concurrent 高并发下，放行的请求数量不能超过阈值
+func TestLocalLimiters_Concurrent(t *testing.T) {
+	limiters := map[string]Limiter{
+		"滑动窗口": NewLocalSlidingWindowLimiter(time.Minute, 100),
+		"固定窗口": NewLocalFixedWindowLimiter(time.Hour, 100),
+		"令牌桶":  NewLocalTokenBucketLimiter(100, 0.001),
+	}
+	for name, l := range limiters {
+		t.Run(name, func(t *testing.T) {
+			var allowed atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						limit, err := l.Limit(context.Background(), "key")
+						if err == nil && !limit {
+							allowed.Add(1)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if allowed.Load() != 100 {
+				t.Fatalf("want=100，got=%d", allowed.Load())
+			}
+		})
+	}
+}
+
+func TestLocalStore_Evict(t *testing.T) {
+	// 超出容量，淘汰最久没有访问的key
+	l := NewLocalFixedWindowLimiter(time.Hour, 1, WithMaxKeys(localShardCount))
+	ctx := context.Background()
+	for i := 0; i < 10000; i++ {
+		l.Limit(ctx, strconv.Itoa(i))
+	}
+	if n := l.store.len(); n > 2*localShardCount {
+		t.Fatalf("保存的key数量超出上限，got=%d", n)
+	}
+	// 空闲的key被淘汰
+	s := NewLocalSlidingWindowLimiter(time.Hour, 1, WithIdleTimeout(10*time.Millisecond))
+	for i := 0; i < 100; i++ {
+		s.Limit(ctx, strconv.Itoa(i))
+	}
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < localShardCount*10; i++ {
+		s.Limit(ctx, "new"+strconv.Itoa(i))
+	}
+	for i := 0; i < 100; i++ {
+		if limit, _ := s.Limit(ctx, strconv.Itoa(i)); limit {
+			t.Fatalf("空闲的key应该被淘汰，key=%d", i)
+		}
+	}
+}
+
+func TestLocalLimiters_Wait(t *testing.T) {
+	limiters := map[string]BlockingLimiter{
+		"滑动窗口": NewLocalSlidingWindowLimiter(50*time.Millisecond, 2),
+		"固定窗口": NewLocalFixedWindowLimiter(50*time.Millisecond, 2),
+		"令牌桶":  NewLocalTokenBucketLimiter(2, 40),
+	}
+	for name, l := range limiters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Now()
+			// 阈值为2，之后的请求需要等待配额，而不是被拒绝
+			// 固定窗口可能刚好跨过窗口的边界，6个请求至少跨过一个完整的窗口
+			for i := 0; i < 6; i++ {
+				if err := l.Wait(ctx, "key"); err != nil {
+					t.Fatalf("第%d个请求等待失败，err=%v", i+1, err)
+				}
+			}
+			if time.Since(start) < 45*time.Millisecond {
+				t.Fatalf("超出阈值的请求应该等待，cost=%s", time.Since(start))
+			}
+			// 需要等待的时间超过ctx的截止时间，直接返回
+			l.Limit(ctx, "key")
+			l.Limit(ctx, "key")
+			ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+			defer cancel()
+			err := l.Wait(ctx, "key")
+			if err != ErrWaitExceedsDeadline && err != context.DeadlineExceeded {
+				t.Fatalf("应该返回超时错误，err=%v", err)
+			}
+		})
+	}
+}
+
+func TestLocalTokenBucketLimiter_Reserve(t *testing.T) {
+	l := NewLocalTokenBucketLimiter(1, 10)
+	ctx := context.Background()
+	r, _ := l.Reserve(ctx, "key")
+	if !r.OK || r.Delay != 0 {
+		t.Fatalf("桶内有令牌，应该直接预约成功，got=%+v", r)
+	}
+	// 预支未来的令牌，第2个预约等待约100ms，第3个预约等待约200ms
+	r2, _ := l.Reserve(ctx, "key")
+	r3, _ := l.Reserve(ctx, "key")
+	if !r2.OK || !r3.OK || r3.Delay <= r2.Delay || r2.Delay < 90*time.Millisecond {
+		t.Fatalf("令牌不足时应该预支未来的令牌，r2=%+v，r3=%+v", r2, r3)
+	}
+	// 预支之后，普通请求被限流
+	if limit, _ := l.Limit(ctx, "key"); !limit {
+		t.Fatal("令牌被预支，应该限流")
+	}
+}
+
+// TestLocalTokenBucketLimiter_WaitCancel 等待的过程中ctx被取消，归还预支的令牌
+func TestLocalTokenBucketLimiter_WaitCancel(t *testing.T) {
+	l := NewLocalTokenBucketLimiter(1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Limit(ctx, "key")
+	// 令牌耗尽，预支下一个令牌需要等待100ms，20ms后放弃
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := l.Wait(ctx, "key"); err != context.Canceled {
+		t.Fatalf("want=%v，got=%v", context.Canceled, err)
+	}
+	// 预支的令牌已经归还，下一个令牌在100ms内生成；没有归还时需要等待约180ms
+	r, _ := l.Reserve(context.Background(), "key")
+	if !r.OK || r.Delay > 100*time.Millisecond {
+		t.Fatalf("放弃执行之后应该归还预支的令牌，got=%+v", r)
+	}
+	// 桶内有令牌时归还，最多补满
+	full := NewLocalTokenBucketLimiter(1, 10)
+	r, _ = full.Reserve(context.Background(), "key")
+	r.Cancel(context.Background())
+	r.Cancel(context.Background())
+	full.Limit(context.Background(), "key")
+	if limit, _ := full.Limit(context.Background(), "key"); !limit {
+		t.Fatal("归还的令牌不能超过桶的容量")
+	}
+}
+
+// TestLocalTokenBucketLimiter_EvictDebt 预支的令牌还没有还清时，空闲时间超过默认的空闲时间也不会被淘汰
+func TestLocalTokenBucketLimiter_EvictDebt(t *testing.T) {
+	// 默认的空闲时间是补满需要的时间100ms
+	l := NewLocalTokenBucketLimiter(1, 10)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if r, _ := l.Reserve(ctx, "key"); !r.OK {
+			t.Fatal("没有截止时间，应该预约成功")
+		}
+	}
+	// 预支了4个令牌，400ms之后才能还清
+	time.Sleep(150 * time.Millisecond)
+	if limit, _ := l.Limit(ctx, "key"); !limit {
+		t.Fatal("预支的令牌还没有还清，key不能被淘汰，应该限流")
+	}
+	if l.store.len() != 1 {
+		t.Fatalf("key不应该被淘汰，got=%d", l.store.len())
+	}
+}
@@ -0,0 +1,109 @@
+package limitx
+
+import (
+	"context"
+	"time"
+)
+
+// LocalSlidingWindowLimiter 本地滑动窗口限流，不依赖redis，只对当前实例生效
+//
+//	每个key使用环形队列记录窗口内的请求时间，队列按需扩容，最多保存rate个时间戳
+//	内存占用和窗口内实际的请求数量成正比，阈值很大时可以使用 RedisSlidingWindowCounterLimiter 的近似算法
+type LocalSlidingWindowLimiter struct {
+	interval time.Duration // 滑动窗口的大小，时间间隔
+	rate     int           // 阈值，允许的请求数量
+	store    *localStore[slidingWindowState]
+}
+
+// slidingWindowMinCap 环形队列的初始容量
+const slidingWindowMinCap = 8
+
+// slidingWindowState 环形队列，保存窗口内请求的时间戳（纳秒）
+type slidingWindowState struct {
+	times []int64
+	head  int // 最早的请求的下标
+	count int // 窗口内的请求数量
+}
+
+// push 记录一个请求，队列已满时扩容为原来的两倍，最多扩容到limit
+func (s *slidingWindowState) push(now int64, limit int) {
+	if s.count == len(s.times) {
+		times := make([]int64, min(max(2*len(s.times), slidingWindowMinCap), limit))
+		// 按照时间顺序拷贝，最早的请求放在下标0
+		n := copy(times, s.times[s.head:])
+		copy(times[n:], s.times[:s.head])
+		s.times = times
+		s.head = 0
+	}
+	s.times[(s.head+s.count)%len(s.times)] = now
+	s.count++
+}
+
+func NewLocalSlidingWindowLimiter(interval time.Duration, rate int,
+	opts ...LocalOption) *LocalSlidingWindowLimiter {
+	if interval <= 0 {
+		panic("limitx: 滑动窗口的大小必须大于0")
+	}
+	return &LocalSlidingWindowLimiter{
+		interval: interval,
+		rate:     rate,
+		store: newLocalStore(func() *slidingWindowState {
+			return &slidingWindowState{}
+		}, newLocalOptions(interval, opts)),
+	}
+}
+
+func (l *LocalSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := l.Allow(ctx, key)
+	return !decision.Allowed, err
+}
+
+// Allow 判断是否放行key的本次请求，并返回剩余配额和重试时间
+func (l *LocalSlidingWindowLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	now := time.Now()
+	decision := Decision{Limit: l.rate}
+	l.store.do(key, now, func(s *slidingWindowState) {
+		nowNano := now.UnixNano()
+		window := l.interval.Nanoseconds()
+		// 删除过期请求
+		for s.count > 0 && s.times[s.head] <= nowNano-window {
+			s.head = (s.head + 1) % len(s.times)
+			s.count--
+		}
+		if s.count == 0 && l.rate <= 0 {
+			// 阈值为0，拒绝所有请求
+			decision.ResetAt = now.Add(l.interval)
+			decision.RetryAfter = l.interval
+			return
+		}
+		if s.count >= l.rate {
+			// 执行限流，最早的请求移出窗口后，才会空出一个配额
+			newest := s.times[(s.head+s.count-1)%len(s.times)]
+			decision.ResetAt = time.Unix(0, newest+window)
+			decision.RetryAfter = time.Duration(s.times[s.head] + window - nowNano)
+			return
+		}
+		// 记录当前请求
+		s.push(nowNano, l.rate)
+		decision.Allowed = true
+		decision.Remaining = l.rate - s.count
+		decision.ResetAt = now.Add(l.interval)
+	})
+	return decision, nil
+}
+
+// Reserve 预约一个配额，有配额时直接占用；没有配额时返回OK=false，Delay是建议的重试时间
+func (l *LocalSlidingWindowLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	decision, err := l.Allow(ctx, key)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reserveByDecision(decision), nil
+}
+
+// Wait 阻塞直到获得配额，或者ctx结束
+func (l *LocalSlidingWindowLimiter) Wait(ctx context.Context, key string) error {
+	return wait(ctx, func(ctx context.Context) (Reservation, error) {
+		return l.Reserve(ctx, key)
+	})
+}
@@ -0,0 +1,156 @@
+package limitx
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+const (
+	localShardCount     = 64    // 分片数量，减少高并发下的锁竞争
+	defaultLocalMaxKeys = 65536 // 默认最多保存的key数量
+)
+
+// localOptions 本地限流器的配置
+type localOptions struct {
+	maxKeys     int           // 最多保存的key数量，超过后淘汰最久没有访问的key
+	idleTimeout time.Duration // key超过这个时间没有被访问，就会被淘汰
+}
+
+// LocalOption 本地限流器的配置选项
+type LocalOption func(*localOptions)
+
+// WithMaxKeys 设置最多保存的key数量，超过后淘汰最久没有访问的key，用来限制内存占用
+func WithMaxKeys(n int) LocalOption {
+	return func(o *localOptions) {
+		o.maxKeys = n
+	}
+}
+
+// WithIdleTimeout 设置key的空闲时间，超过这个时间没有被访问的key会被淘汰
+//
+//	默认值是限流状态自然恢复所需的时间（滑动窗口、固定窗口为窗口大小，令牌桶为补满的时间），
+//	设置得比默认值更小，被淘汰的key的限流状态会提前重置
+func WithIdleTimeout(d time.Duration) LocalOption {
+	return func(o *localOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// localStore 分片保存每个key的限流状态
+//
+//	每个分片使用LRU链表管理key，访问key时顺便淘汰空闲的key和超出容量的key，不需要后台协程
+type localStore[T any] struct {
+	seed     maphash.Seed
+	shards   [localShardCount]localShard[T]
+	newState func() *T // 创建key的初始状态
+	// keep 空闲的key是否需要保留，为nil时空闲的key都会被淘汰
+	// 比如令牌桶预支的令牌还没有还清，淘汰之后重新创建的状态会多放行请求
+	keep func(state *T, now time.Time) bool
+}
+
+type localShard[T any] struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List // 链表头部是最近访问的key
+	maxKeys int
+	idle    time.Duration
+}
+
+type localEntry[T any] struct {
+	key      string
+	state    *T
+	lastSeen time.Time // 最后一次访问的时间
+}
+
+func newLocalStore[T any](newState func() *T, opts localOptions) *localStore[T] {
+	if opts.maxKeys <= 0 {
+		opts.maxKeys = defaultLocalMaxKeys
+	}
+	// 每个分片的容量，向上取整
+	perShard := (opts.maxKeys + localShardCount - 1) / localShardCount
+	s := &localStore[T]{
+		seed:     maphash.MakeSeed(),
+		newState: newState,
+	}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]*list.Element)
+		s.shards[i].lru = list.New()
+		s.shards[i].maxKeys = perShard
+		s.shards[i].idle = opts.idleTimeout
+	}
+	return s
+}
+
+// do 在分片锁的保护下，使用key的限流状态执行fn
+func (s *localStore[T]) do(key string, now time.Time, fn func(state *T)) {
+	shard := &s.shards[maphash.String(s.seed, key)%localShardCount]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	// 淘汰空闲的key
+	shard.evictIdle(now, s.keep)
+	var entry *localEntry[T]
+	if elem, ok := shard.items[key]; ok {
+		entry = elem.Value.(*localEntry[T])
+		shard.lru.MoveToFront(elem)
+	} else {
+		entry = &localEntry[T]{key: key, state: s.newState()}
+		shard.items[key] = shard.lru.PushFront(entry)
+		// 超出容量，淘汰最久没有访问的key
+		for shard.lru.Len() > shard.maxKeys {
+			shard.remove(shard.lru.Back())
+		}
+	}
+	entry.lastSeen = now
+	fn(entry.state)
+}
+
+// evictIdle 从链表尾部开始，淘汰空闲时间超过idle的key
+//
+//	keep返回true的key不会被淘汰，视为刚刚访问过，移动到链表头部，空闲时间超过idle之后再次检查
+func (s *localShard[T]) evictIdle(now time.Time, keep func(state *T, now time.Time) bool) {
+	if s.idle <= 0 {
+		return
+	}
+	for elem := s.lru.Back(); elem != nil; elem = s.lru.Back() {
+		entry := elem.Value.(*localEntry[T])
+		if now.Sub(entry.lastSeen) < s.idle {
+			return
+		}
+		if keep != nil && keep(entry.state, now) {
+			entry.lastSeen = now
+			s.lru.MoveToFront(elem)
+			continue
+		}
+		s.remove(elem)
+	}
+}
+
+func (s *localShard[T]) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.items, elem.Value.(*localEntry[T]).key)
+}
+
+// len 当前保存的key数量
+func (s *localStore[T]) len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += s.shards[i].lru.Len()
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+// newLocalOptions 应用用户的配置，idle是默认的空闲时间
+func newLocalOptions(idle time.Duration, opts []LocalOption) localOptions {
+	o := localOptions{
+		maxKeys:     defaultLocalMaxKeys,
+		idleTimeout: idle,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,121 @@
+package limitx
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// LocalTokenBucketLimiter 本地令牌桶限流，不依赖redis，只对当前实例生效
+//
+//	桶内最多存放capacity个令牌，每秒生成rate个令牌，每个请求消耗一个令牌
+type LocalTokenBucketLimiter struct {
+	capacity int     // 桶的容量，允许的最大突发请求数
+	rate     float64 // 令牌的生成速率，每秒生成的令牌数
+	store    *localStore[tokenBucketState]
+}
+
+// tokenBucketState 桶内剩余的令牌数和上一次补充令牌的时间
+type tokenBucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLocalTokenBucketLimiter(capacity int, rate float64,
+	opts ...LocalOption) *LocalTokenBucketLimiter {
+	if capacity <= 0 || rate <= 0 {
+		panic("limitx: 令牌桶的容量和令牌的生成速率必须大于0")
+	}
+	// 默认的空闲时间 == 把空桶补满需要的时间，之后重置的状态和补满的状态一样
+	idle := time.Duration(float64(capacity) / rate * float64(time.Second))
+	l := &LocalTokenBucketLimiter{
+		capacity: capacity,
+		rate:     rate,
+		store: newLocalStore(func() *tokenBucketState {
+			return &tokenBucketState{tokens: float64(capacity)}
+		}, newLocalOptions(idle, opts)),
+	}
+	// 预支的令牌还没有还清时，空闲时间超过idle也不能淘汰，否则重新创建的key是满的，会多放行请求
+	l.store.keep = func(s *tokenBucketState, now time.Time) bool {
+		return s.tokens+now.Sub(s.last).Seconds()*rate < 0
+	}
+	return l
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := l.Allow(ctx, key)
+	return !decision.Allowed, err
+}
+
+// Allow 判断是否放行key的本次请求，并返回剩余令牌数和重试时间
+func (l *LocalTokenBucketLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	decision, _ := l.take(time.Now(), key, 0)
+	return decision, nil
+}
+
+// Reserve 预约一个令牌，令牌不足时预支未来生成的令牌，预约成功后需要等待Delay再执行
+//
+//	需要等待的时间超过ctx的截止时间时，不会预支令牌，返回OK=false
+func (l *LocalTokenBucketLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	decision, delay := l.take(time.Now(), key, maxWait(ctx))
+	return Reservation{
+		OK:    decision.Allowed,
+		Delay: delay,
+		cancel: func(ctx context.Context) error {
+			// 归还一个令牌，最多补满
+			now := time.Now()
+			l.store.do(key, now, func(s *tokenBucketState) {
+				l.refill(s, now)
+				s.tokens = math.Min(float64(l.capacity), s.tokens+1)
+			})
+			return nil
+		},
+	}, nil
+}
+
+// Wait 阻塞直到获得令牌，或者ctx结束
+func (l *LocalTokenBucketLimiter) Wait(ctx context.Context, key string) error {
+	return wait(ctx, func(ctx context.Context) (Reservation, error) {
+		return l.Reserve(ctx, key)
+	})
+}
+
+// take 获取一个令牌，返回限流决策和需要等待的时间
+//
+//	maxDelay 最多允许等待的时间，0表示不等待，-1表示不限制；允许等待时，令牌可以扣成负数
+func (l *LocalTokenBucketLimiter) take(now time.Time, key string,
+	maxDelay time.Duration) (Decision, time.Duration) {
+	decision := Decision{Limit: l.capacity}
+	var delay time.Duration
+	l.store.do(key, now, func(s *tokenBucketState) {
+		l.refill(s, now)
+		// 令牌不足时，等待补充到1个令牌需要的时间
+		if s.tokens < 1 {
+			delay = l.duration(1 - s.tokens)
+		}
+		if maxDelay < 0 || delay <= maxDelay {
+			// 放行或者预约成功，预约时令牌会被扣成负数
+			s.tokens--
+			decision.Allowed = true
+		} else {
+			decision.RetryAfter = delay
+		}
+		decision.Remaining = max(0, int(s.tokens))
+		decision.ResetAt = now.Add(l.duration(float64(l.capacity) - s.tokens))
+	})
+	return decision, delay
+}
+
+// refill 按照流逝的时间补充令牌，最多补满
+func (l *LocalTokenBucketLimiter) refill(s *tokenBucketState, now time.Time) {
+	if !s.last.IsZero() {
+		elapsed := now.Sub(s.last).Seconds()
+		s.tokens = math.Min(float64(l.capacity), s.tokens+elapsed*l.rate)
+	}
+	s.last = now
+}
+
+// duration 生成n个令牌需要的时间
+func (l *LocalTokenBucketLimiter) duration(n float64) time.Duration {
+	return time.Duration(math.Ceil(n / l.rate * float64(time.Second)))
+}
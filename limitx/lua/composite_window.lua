@@ -0,0 +1,37 @@
+-- 组合限流，一次检查一个请求命中的多条滑动窗口规则
+-- 先检查所有规则，任意一条规则超出阈值就限流，并且不记录本次请求
+-- 所有规则都没有超出阈值，才在每条规则的有序集合中记录本次请求
+-- 返回值：{触发限流的规则下标(从1开始，0表示放行), 需要等待的毫秒数}
+
+-- 当前时间戳
+local now = tonumber(ARGV[1])
+
+-- 本次请求的唯一标识，避免同一毫秒内的多个请求被合并成一个
+local member = now .. '-' .. ARGV[2]
+
+-- 第一遍：删除过期请求，统计每条规则窗口内的请求数量
+for i, key in ipairs(KEYS) do
+    -- ARGV[3]开始，每条规则两个参数：窗口大小（毫秒），阈值
+    local window = tonumber(ARGV[2 * i + 1])
+    local threshold = tonumber(ARGV[2 * i + 2])
+    local min = now - window
+    redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
+    local cnt = redis.call('ZCARD', key)
+    if cnt >= threshold then
+        -- 第(cnt-threshold+1)早的请求移出窗口后，才会空出一个配额
+        local retryAfter = window
+        local oldest = redis.call('ZRANGE', key, cnt - threshold, cnt - threshold, 'WITHSCORES')
+        if oldest[2] then
+            retryAfter = tonumber(oldest[2]) + window - now
+        end
+        return {i, retryAfter}
+    end
+end
+
+-- 第二遍：所有规则都放行，记录本次请求
+for i, key in ipairs(KEYS) do
+    local window = tonumber(ARGV[2 * i + 1])
+    redis.call('ZADD', key, now, member)
+    redis.call('PEXPIRE', key, window)
+end
+return {0, 0}
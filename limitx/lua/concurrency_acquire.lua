@@ -0,0 +1,32 @@
+-- 并发限流，获取一个槽位
+-- 使用有序集合保存持有槽位的租约，member是租约id，score是租约的过期时间
+-- 持有者崩溃没有释放槽位时，租约过期后槽位会被自动回收
+-- 返回值：1获取成功，0没有空闲的槽位
+
+-- 限流对象，有序集合
+local key = KEYS[1]
+
+-- 当前时间戳（毫秒）
+local now = tonumber(ARGV[1])
+
+-- 租约的有效期（毫秒）
+local ttl = tonumber(ARGV[2])
+
+-- 最大并发数
+local max = tonumber(ARGV[3])
+
+-- 租约id
+local id = ARGV[4]
+
+-- 回收过期的租约
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now)
+
+-- 没有空闲的槽位
+if redis.call('ZCARD', key) >= max then
+    return 0
+end
+
+redis.call('ZADD', key, now + ttl, id)
+-- 所有租约都过期后，有序集合会被删除
+redis.call('PEXPIRE', key, ttl)
+return 1
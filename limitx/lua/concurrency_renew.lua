@@ -0,0 +1,18 @@
+-- 并发限流，续约
+-- 租约还存在时，延长租约的过期时间
+-- 返回值：1续约成功，0租约已经过期被回收
+
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+local id = ARGV[3]
+
+if not redis.call('ZSCORE', key, id) then
+    return 0
+end
+redis.call('ZADD', key, now + ttl, id)
+-- 有序集合的过期时间不能早于最晚的租约
+if redis.call('PTTL', key) < ttl then
+    redis.call('PEXPIRE', key, ttl)
+end
+return 1
@@ -1,7 +1,8 @@
--- 使用 ZADD 记录每个请求的时间戳。
+-- 使用 ZADD 记录每个请求的时间戳，member是时间戳+唯一id
 -- 使用 ZCOUNT 计算当前时间窗口内的请求数量
 -- 使用 ZREMRANGEBYSCORE 删除过期的请求记录
 -- 使用 PEXPIRE 设置有序集合的过期时间，保证到期后有序集合会被删除
+-- 返回值：{是否放行(1放行，0限流), 剩余配额, 配额完全恢复的毫秒数, 需要等待的毫秒数}
 
 -- 限流对象，有序集合
 local key = KEYS[1]
@@ -15,6 +16,9 @@ local threshold = tonumber( ARGV[2])
 -- 当前时间戳
 local now = tonumber(ARGV[3])
 
+-- 请求的唯一id，同一毫秒内的多个请求使用不同的member，避免互相覆盖
+local id = ARGV[4]
+
 -- 窗口的起始时间
 local min = now - window
 
@@ -30,15 +34,27 @@ local cnt = redis.call('ZCOUNT', key, min, '+inf') -- '-inf', '+inf'
 -- 判断是否触发限流
 if cnt >= threshold then
     -- 执行限流
-    return "true"
+    -- 第(cnt-threshold+1)早的请求移出窗口后，才会空出一个配额
+    local oldest = redis.call('ZRANGE', key, cnt - threshold, cnt - threshold, 'WITHSCORES')
+    local retryAfter = window
+    if oldest[2] then
+        retryAfter = tonumber(oldest[2]) + window - now
+    end
+    -- 最晚的请求移出窗口后，配额完全恢复
+    local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+    local reset = window
+    if newest[2] then
+        reset = tonumber(newest[2]) + window - now
+    end
+    return {0, 0, reset, retryAfter}
 else
-    -- 将当前请求的时间戳当作score和member插入到redis的有序集合中
+    -- 将当前请求的时间戳当作score，时间戳+唯一id当作member，插入到redis的有序集合中
     --    参数一：命令('ZADD')
     --    参数二：key为有序集合，表示限流的对象
     --    参数三：score，排序分数，redis会根据score的值对集合进行排序
-    --    参数四：member，有序集合中的实际值
-    redis.call('ZADD', key, now, now)
+    --    参数四：member，有序集合中的实际值，同一毫秒内的请求member不同，不会互相覆盖
+    redis.call('ZADD', key, now, now .. ':' .. id)
     -- 设置有序集合的过期时间 == 窗口大小，到期后有序集合会被删除
     redis.call('PEXPIRE', key, window)
-    return "false"
+    return {1, threshold - cnt - 1, window, 0}
 end
\ No newline at end of file
@@ -0,0 +1,72 @@
+-- 滑动窗口计数器：保存当前固定窗口和上一个固定窗口的计数，按照时间比例估算滑动窗口内的请求数量
+--     估算值 = 上一个窗口的计数 * 上一个窗口和滑动窗口重叠的比例 + 当前窗口的计数
+-- 使用 HASH 保存三个字段：start（当前窗口的起始时间）、cur（当前窗口的计数）、prev（上一个窗口的计数）
+-- 每个key的内存占用是固定的，和请求速率无关
+-- 返回值：{是否放行(1放行，0限流), 剩余配额, 配额完全恢复的毫秒数, 需要等待的毫秒数}
+
+-- 限流对象，hash
+local key = KEYS[1]
+
+-- 窗口大小（时间间隔的毫秒数）
+local window = tonumber(ARGV[1])
+
+-- 阈值（系统最高处理请求的数量）
+local threshold = tonumber(ARGV[2])
+
+-- 当前时间戳
+local now = tonumber(ARGV[3])
+
+-- 当前时间所在固定窗口的起始时间
+local start = now - now % window
+
+local state = redis.call('HMGET', key, 'start', 'cur', 'prev')
+local lastStart = tonumber(state[1])
+local cur = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+
+if lastStart ~= start then
+    if lastStart == start - window then
+        -- 进入了下一个窗口，当前窗口变成上一个窗口
+        prev = cur
+    else
+        -- 超过一个窗口没有请求，两个窗口的计数都过期了
+        prev = 0
+    end
+    cur = 0
+end
+
+-- 当前窗口已经过去的时间
+local elapsed = now - start
+
+-- 估算滑动窗口[now-window, now]内的请求数量
+local estimated = prev * (window - elapsed) / window + cur
+
+if threshold <= 0 then
+    -- 阈值为0，拒绝所有请求，不会有配额恢复
+    return {0, 0, window, window}
+end
+
+if estimated + 1 > threshold then
+    -- 执行限流，计算估算值降到threshold-1以下需要等待的时间
+    local retryAfter
+    if cur + 1 > threshold then
+        -- 当前窗口的计数已经超过阈值，需要等到下一个窗口，当前窗口的计数随着时间衰减
+        --     cur * (window - t) / window + 1 <= threshold
+        retryAfter = window - elapsed + math.ceil(window * (cur + 1 - threshold) / cur)
+    else
+        -- 等待上一个窗口的计数衰减
+        --     prev * (window - t) / window + cur + 1 <= threshold
+        retryAfter = math.ceil(window - (threshold - cur - 1) * window / prev) - elapsed
+    end
+    if retryAfter < 1 then
+        retryAfter = 1
+    end
+    -- 当前窗口的计数完全衰减后，配额完全恢复
+    return {0, 0, 2 * window - elapsed, retryAfter}
+end
+
+cur = cur + 1
+redis.call('HSET', key, 'start', start, 'cur', cur, 'prev', prev)
+-- 当前窗口的计数在下一个窗口结束后才会过期
+redis.call('PEXPIRE', key, 2 * window - elapsed)
+return {1, math.floor(threshold - estimated - 1), 2 * window - elapsed, 0}
@@ -0,0 +1,60 @@
+-- 令牌桶限流
+-- 使用 HASH 保存桶内剩余的令牌数(tokens)和上一次补充令牌的时间(ts)
+-- 每次请求按照流逝的时间补充令牌，令牌足够则放行并扣减，不够则限流
+-- 允许等待时（预约），令牌可以扣成负数，表示预支了未来生成的令牌，需要等待令牌补充后才能执行
+-- 使用 PEXPIRE 设置过期时间，桶被补满后就没有保存的必要了
+-- 返回值：{是否放行(1放行/预约成功，0限流), 剩余令牌数, 桶被补满的毫秒数, 需要等待的毫秒数}
+
+-- 限流对象
+local key = KEYS[1]
+
+-- 桶的容量（允许的最大突发请求数）
+local capacity = tonumber(ARGV[1])
+
+-- 令牌的生成速率（每毫秒生成的令牌数）
+local rate = tonumber(ARGV[2])
+
+-- 当前时间戳（毫秒）
+local now = tonumber(ARGV[3])
+
+-- 本次请求需要的令牌数，负数表示归还令牌（取消预约）
+local requested = tonumber(ARGV[4])
+
+-- 最多允许等待的毫秒数，0表示不等待，-1表示不限制
+local maxWait = tonumber(ARGV[5])
+
+-- 获取桶内剩余的令牌数和上一次补充令牌的时间
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+
+-- 桶不存在（第一次请求，或者已经过期），视为装满令牌
+if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+end
+
+-- 按照流逝的时间补充令牌，最多补满
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+-- 判断是否触发限流
+local allowed = 0
+-- 令牌不足时，等待补充到requested个令牌需要的时间
+local wait = 0
+if tokens < requested then
+    wait = math.ceil((requested - tokens) / rate)
+end
+if maxWait < 0 or wait <= maxWait then
+    -- 放行或者预约成功，预约时令牌会被扣成负数；归还令牌时最多补满
+    tokens = math.min(capacity, tokens - requested)
+    allowed = 1
+end
+
+-- 保存桶的状态
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+-- 过期时间 == 把桶补满需要的时间，到期后桶会被删除，下次请求时视为装满令牌
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('PEXPIRE', key, reset + 1)
+
+return {allowed, math.max(0, math.floor(tokens)), reset, wait}
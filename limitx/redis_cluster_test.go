@@ -0,0 +1,112 @@
+package limitx
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newClusterClient 连接redis集群，需要设置环境变量REDIS_CLUSTER_ADDRS，比如：
+//
+//	REDIS_CLUSTER_ADDRS=127.0.0.1:7000,127.0.0.1:7001,127.0.0.1:7002 go test ./limitx/
+func newClusterClient(t *testing.T) *redis.ClusterClient {
+	addrs := os.Getenv("REDIS_CLUSTER_ADDRS")
+	if addrs == "" {
+		t.Skip("没有设置REDIS_CLUSTER_ADDRS，跳过redis集群测试")
+	}
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: strings.Split(addrs, ","),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	// 清空脚本缓存，验证NOSCRIPT时会自动使用EVAL加载脚本
+	err := client.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+		return c.ScriptFlush(ctx).Err()
+	})
+	if err != nil {
+		t.Fatalf("连接redis集群失败，err=%v", err)
+	}
+	return client
+}
+
+func TestRedisLimiters_Cluster(t *testing.T) {
+	client := newClusterClient(t)
+	ctx := context.Background()
+	prefix := "limitx:test:" + time.Now().Format("150405.000000")
+	limiters := map[string]Limiter{
+		"滑动窗口": NewRedisSlidingWindowLimiter(client, time.Minute, 3, WithKeyPrefix(prefix)),
+		"令牌桶":  NewRedisTokenBucketLimiter(client, 3, 0.001, WithKeyPrefix(prefix)),
+	}
+	for name, l := range limiters {
+		t.Run(name, func(t *testing.T) {
+			// 使用不同的key，分布在不同的slot上
+			for _, key := range []string{name + ":a", name + ":b", name + ":c"} {
+				for i := 0; i < 3; i++ {
+					limit, err := l.Limit(ctx, key)
+					if err != nil || limit {
+						t.Fatalf("key=%s，第%d个请求应该放行，limit=%v，err=%v", key, i+1, limit, err)
+					}
+				}
+				limit, err := l.Limit(ctx, key)
+				if err != nil || !limit {
+					t.Fatalf("key=%s，超出阈值应该限流，limit=%v，err=%v", key, limit, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisCompositeLimiter_Cluster(t *testing.T) {
+	client := newClusterClient(t)
+	ctx := context.Background()
+	tag := "limitx:test:" + time.Now().Format("150405.000000")
+	l := NewRedisCompositeLimiter(client, WithKeyPrefix("limiter"), WithHashTag(tag))
+	user := Rule{Name: "user", Interval: time.Minute, Rate: 2}
+	ip := Rule{Name: "ip", Interval: time.Minute, Rate: 3}
+	hits := func(uid string) []Hit {
+		return []Hit{{Rule: user, Key: "user:" + uid}, {Rule: ip, Key: "ip:127.0.0.1"}}
+	}
+	for _, uid := range []string{"1", "1", "2"} {
+		d, err := l.Check(ctx, hits(uid))
+		if err != nil || !d.Allowed {
+			t.Fatalf("应该放行，decision=%+v，err=%v", d, err)
+		}
+	}
+	d, err := l.Check(ctx, hits("1"))
+	if err != nil || d.Allowed || d.Rule != "user" {
+		t.Fatalf("应该触发user规则，decision=%+v，err=%v", d, err)
+	}
+	d, err = l.Check(ctx, hits("3"))
+	if err != nil || d.Allowed || d.Rule != "ip" {
+		t.Fatalf("应该触发ip规则，decision=%+v，err=%v", d, err)
+	}
+}
+
+func TestRedisOptions_Key(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []RedisOption
+		want string
+	}{
+		{name: "没有前缀", want: "user:1"},
+		{name: "前缀", opts: []RedisOption{WithKeyPrefix("limiter")}, want: "limiter{user:1}"},
+		{
+			name: "hash tag",
+			opts: []RedisOption{WithKeyPrefix("limiter"), WithHashTag("login")},
+			want: "limiter{login}:user:1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newRedisOptions(tc.opts).key("user:1"); got != tc.want {
+				t.Fatalf("want=%s，got=%s", tc.want, got)
+			}
+		})
+	}
+}
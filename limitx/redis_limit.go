@@ -2,36 +2,102 @@ package limitx
 
 import (
 	_ "embed"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/net/context"
+	"sync/atomic"
 	"time"
 )
 
 //go:embed lua/slide_window.lua
 var luaSlideWindow string
 
+// slideWindowScript 使用EVALSHA执行，redis中没有缓存脚本（NOSCRIPT）时，自动使用EVAL执行并缓存
+var slideWindowScript = redis.NewScript(luaSlideWindow)
+
 // RedisSlidingWindowLimiter redis滑动窗口限流
 type RedisSlidingWindowLimiter struct {
-	cmd      redis.Cmdable // redis客户端
+	cmd    redis.Cmdable // redis客户端
+	params atomic.Pointer[slidingWindowParams]
+	opts   redisOptions
+}
+
+// slidingWindowParams 滑动窗口的参数，使用atomic.Pointer整体替换，保证interval和rate同时生效
+type slidingWindowParams struct {
 	interval time.Duration // 滑动窗口的大小，时间间隔
 	rate     int           // 阈值，允许的请求数量
 	// interval内允许rate个请求，比如10s内允许100个请求
 }
 
 func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration,
-	rate int) *RedisSlidingWindowLimiter {
-	return &RedisSlidingWindowLimiter{
-		cmd:      cmd,
+	rate int, opts ...RedisOption) *RedisSlidingWindowLimiter {
+	r := &RedisSlidingWindowLimiter{
+		cmd:  cmd,
+		opts: newRedisOptions(opts),
+	}
+	r.Update(interval, rate)
+	return r
+}
+
+// Update 修改窗口大小和阈值，窗口内已经记录的请求保存在redis中，不会丢失
+func (r *RedisSlidingWindowLimiter) Update(interval time.Duration, rate int) {
+	r.params.Store(&slidingWindowParams{
 		interval: interval,
 		rate:     rate,
-	}
+	})
 }
+
 func (r *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return r.cmd.Eval(ctx, // 上下文，用于控制请求的生命周期
-		luaSlideWindow,            // lua脚本
-		[]string{key},             // KEY数组
-		r.interval.Milliseconds(), // ARGV数组，第一个元素，时间间隔的毫秒数
-		r.rate,                    // ARGV数组，第二个元素，允许的请求数量
-		time.Now().UnixMilli()). // 当前时间
-		Bool()
+	decision, err := r.Allow(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return !decision.Allowed, nil
+}
+
+// Allow 判断是否放行key的本次请求，并返回剩余配额和重试时间
+func (r *RedisSlidingWindowLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	now := time.Now()
+	params := r.params.Load()
+	res, err := slideWindowScript.Run(ctx, // 上下文，用于控制请求的生命周期
+		r.cmd,                          // redis客户端
+		[]string{r.opts.key(key)},      // KEY数组
+		params.interval.Milliseconds(), // ARGV数组，第一个元素，时间间隔的毫秒数
+		params.rate,                    // ARGV数组，第二个元素，允许的请求数量
+		now.UnixMilli(),                // ARGV数组，第三个元素，当前时间
+		uuid.NewString()).              // ARGV数组，第四个元素，请求的唯一id
+		Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return newDecision(res, params.rate, now), nil
+}
+
+// Reserve 预约一个配额，有配额时直接占用；没有配额时返回OK=false，Delay是建议的重试时间
+func (r *RedisSlidingWindowLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	decision, err := r.Allow(ctx, key)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reserveByDecision(decision), nil
+}
+
+// Wait 阻塞直到获得配额，或者ctx结束
+func (r *RedisSlidingWindowLimiter) Wait(ctx context.Context, key string) error {
+	return wait(ctx, func(ctx context.Context) (Reservation, error) {
+		return r.Reserve(ctx, key)
+	})
+}
+
+// newDecision 将lua脚本的返回值转为Decision
+//
+//	res {是否放行(1放行，0限流), 剩余配额, 配额完全恢复的毫秒数, 需要等待的毫秒数}
+func newDecision(res []int64, limit int, now time.Time) Decision {
+	return Decision{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		ResetAt:    now.Add(time.Duration(res[2]) * time.Millisecond),
+		RetryAfter: time.Duration(res[3]) * time.Millisecond,
+	}
 }
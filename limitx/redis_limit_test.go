@@ -0,0 +1,80 @@
+package limitx
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// TestRedisSlidingWindowLimiter_Decision 使用模拟的时间执行滑动窗口脚本，校验剩余配额、重试时间和配额恢复的时间
+func TestRedisSlidingWindowLimiter_Decision(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	start := time.Now().Truncate(time.Second)
+	allow := func(offset time.Duration) Decision {
+		now := start.Add(offset)
+		res, err := slideWindowScript.Run(ctx, client, []string{key},
+			time.Second.Milliseconds(), 3, now.UnixMilli(), uuid.NewString()).Int64Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newDecision(res, 3, now)
+	}
+
+	testCases := []struct {
+		name       string
+		offset     time.Duration // 请求的时间
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAt    time.Duration // 配额完全恢复的时间（相对于start）
+	}{
+		{name: "第1个请求", offset: 0, allowed: true, remaining: 2, resetAt: time.Second},
+		{name: "第2个请求", offset: 100 * time.Millisecond, allowed: true, remaining: 1, resetAt: 1100 * time.Millisecond},
+		{name: "第3个请求", offset: 200 * time.Millisecond, allowed: true, remaining: 0, resetAt: 1200 * time.Millisecond},
+		// 第1个请求在1000ms移出窗口，最后一个请求在1200ms移出窗口
+		{name: "超出阈值", offset: 300 * time.Millisecond, retryAfter: 700 * time.Millisecond, resetAt: 1200 * time.Millisecond},
+		{name: "被限流的请求不占用配额", offset: 900 * time.Millisecond, retryAfter: 100 * time.Millisecond, resetAt: 1200 * time.Millisecond},
+		{name: "第1个请求移出窗口", offset: time.Second, allowed: true, remaining: 0, resetAt: 2 * time.Second},
+		{name: "再次超出阈值", offset: 1050 * time.Millisecond, retryAfter: 50 * time.Millisecond, resetAt: 2 * time.Second},
+	}
+	for _, tc := range testCases {
+		d := allow(tc.offset)
+		if d.Allowed != tc.allowed || d.Limit != 3 || d.Remaining != tc.remaining || d.RetryAfter != tc.retryAfter {
+			t.Fatalf("%s，want allowed=%v remaining=%d retryAfter=%v，got=%+v",
+				tc.name, tc.allowed, tc.remaining, tc.retryAfter, d)
+		}
+		if want := start.Add(tc.resetAt); !d.ResetAt.Equal(want) {
+			t.Fatalf("%s，want resetAt=%v，got=%v", tc.name, want, d.ResetAt)
+		}
+	}
+}
+
+// TestRedisSlidingWindowLimiter_Allow 使用真实的时间，Allow返回的决策和Limit的结果一致
+func TestRedisSlidingWindowLimiter_Allow(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	key := uuid.NewString()
+	l := NewRedisSlidingWindowLimiter(client, time.Minute, 2, WithKeyPrefix("limitx:test"))
+	t.Cleanup(func() {
+		client.Del(ctx, l.opts.key(key))
+	})
+	for i := 0; i < 2; i++ {
+		d, err := l.Allow(ctx, key)
+		if err != nil || !d.Allowed || d.Remaining != 1-i || d.Limit != 2 {
+			t.Fatalf("第%d个请求应该放行，decision=%+v，err=%v", i+1, d, err)
+		}
+	}
+	d, err := l.Allow(ctx, key)
+	if err != nil || d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > time.Minute {
+		t.Fatalf("超出阈值应该限流，并且在一个窗口内重试，decision=%+v，err=%v", d, err)
+	}
+	if limit, _ := l.Limit(ctx, key); !limit {
+		t.Fatal("超出阈值应该限流")
+	}
+}
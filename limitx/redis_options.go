@@ -0,0 +1,49 @@
+package limitx
+
+// redisOptions redis限流器的配置
+type redisOptions struct {
+	prefix  string // key的前缀
+	hashTag string // 固定的hash tag，所有key都分配到同一个slot
+}
+
+// RedisOption redis限流器的配置选项
+type RedisOption func(*redisOptions)
+
+// WithKeyPrefix 设置key的前缀，key会被格式化为prefix{key}
+//
+//	redis集群只使用{}内的部分计算slot，同一个限流对象派生出的多个key（比如滑动窗口计数器的两个窗口）
+//	会被分配到同一个slot，可以在一个lua脚本中访问
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(o *redisOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithHashTag 设置固定的hash tag，key会被格式化为prefix{tag}:key
+//
+//	组合限流需要在一个lua脚本中访问多条规则的key，redis集群要求这些key在同一个slot，
+//	所以必须设置hash tag；所有key都会落在同一个slot上，流量很大时可以按照业务拆分成多个组合限流器，使用不同的tag
+func WithHashTag(tag string) RedisOption {
+	return func(o *redisOptions) {
+		o.hashTag = tag
+	}
+}
+
+func newRedisOptions(opts []RedisOption) redisOptions {
+	var o redisOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// key 格式化限流对象在redis中的key，没有设置前缀和hash tag时，使用原始的key
+func (o redisOptions) key(key string) string {
+	if o.hashTag != "" {
+		return o.prefix + "{" + o.hashTag + "}:" + key
+	}
+	if o.prefix != "" {
+		return o.prefix + "{" + key + "}"
+	}
+	return key
+}
@@ -0,0 +1,79 @@
+package limitx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/sliding_window_counter.lua
+var luaSlidingWindowCounter string
+
+var slidingWindowCounterScript = redis.NewScript(luaSlidingWindowCounter)
+
+// RedisSlidingWindowCounterLimiter redis滑动窗口计数器限流，近似的滑动窗口
+//
+//	只保存当前固定窗口和上一个固定窗口的计数，按照上一个窗口和滑动窗口重叠的比例估算窗口内的请求数量
+//	每个key只保存三个字段，内存占用和请求速率无关，适合阈值很大的场景（比如每分钟10万个请求）
+//	RedisSlidingWindowLimiter 记录每个请求，结果是精确的，但是内存占用和阈值成正比
+//	估算假设上一个窗口内的请求是均匀分布的，请求不均匀时和精确值有一定的误差
+type RedisSlidingWindowCounterLimiter struct {
+	cmd      redis.Cmdable // redis客户端
+	interval time.Duration // 滑动窗口的大小，时间间隔
+	rate     int           // 阈值，允许的请求数量
+	opts     redisOptions
+}
+
+func NewRedisSlidingWindowCounterLimiter(cmd redis.Cmdable, interval time.Duration,
+	rate int, opts ...RedisOption) *RedisSlidingWindowCounterLimiter {
+	if interval < time.Millisecond || rate <= 0 {
+		panic("limitx: 滑动窗口计数器的窗口大小不能小于1ms，阈值必须大于0")
+	}
+	return &RedisSlidingWindowCounterLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		opts:     newRedisOptions(opts),
+	}
+}
+
+func (r *RedisSlidingWindowCounterLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := r.Allow(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return !decision.Allowed, nil
+}
+
+// Allow 判断是否放行key的本次请求，并返回剩余配额和重试时间
+func (r *RedisSlidingWindowCounterLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	now := time.Now()
+	res, err := slidingWindowCounterScript.Run(ctx, // 上下文，用于控制请求的生命周期
+		r.cmd,                     // redis客户端
+		[]string{r.opts.key(key)}, // KEY数组
+		r.interval.Milliseconds(), // ARGV数组，第一个元素，时间间隔的毫秒数
+		r.rate,                    // ARGV数组，第二个元素，允许的请求数量
+		now.UnixMilli()).          // ARGV数组，第三个元素，当前时间
+		Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return newDecision(res, r.rate, now), nil
+}
+
+// Reserve 预约一个配额，有配额时直接占用；没有配额时返回OK=false，Delay是建议的重试时间
+func (r *RedisSlidingWindowCounterLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	decision, err := r.Allow(ctx, key)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reserveByDecision(decision), nil
+}
+
+// Wait 阻塞直到获得配额，或者ctx结束
+func (r *RedisSlidingWindowCounterLimiter) Wait(ctx context.Context, key string) error {
+	return wait(ctx, func(ctx context.Context) (Reservation, error) {
+		return r.Reserve(ctx, key)
+	})
+}
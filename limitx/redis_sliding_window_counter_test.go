@@ -0,0 +1,233 @@
+package limitx
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+// newRedisClient 连接本地的redis，redis不可用时跳过测试
+func newRedisClient(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("redis不可用，跳过测试，err=%v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+// slidingWindowRunner 使用模拟的时间执行限流脚本，返回是否放行
+type slidingWindowRunner func(ctx context.Context, key string, now int64) (bool, error)
+
+func exactRunner(client redis.Cmdable, window time.Duration, rate int) slidingWindowRunner {
+	return func(ctx context.Context, key string, now int64) (bool, error) {
+		res, err := slideWindowScript.Run(ctx, client, []string{key},
+			window.Milliseconds(), rate, now, uuid.NewString()).Int64Slice()
+		if err != nil {
+			return false, err
+		}
+		return res[0] == 1, nil
+	}
+}
+
+func counterRunner(client redis.Cmdable, window time.Duration, rate int) slidingWindowRunner {
+	return func(ctx context.Context, key string, now int64) (bool, error) {
+		res, err := slidingWindowCounterScript.Run(ctx, client, []string{key},
+			window.Milliseconds(), rate, now).Int64Slice()
+		if err != nil {
+			return false, err
+		}
+		return res[0] == 1, nil
+	}
+}
+
+// simulate 按照时间戳依次发送请求，返回放行的请求的时间戳
+func simulate(t *testing.T, client redis.Cmdable, run slidingWindowRunner, requests []int64) []int64 {
+	ctx := context.Background()
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	var accepted []int64
+	for _, now := range requests {
+		ok, err := run(ctx, key, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			accepted = append(accepted, now)
+		}
+	}
+	return accepted
+}
+
+// maxInWindow 任意一个(now-window, now]窗口内，放行的请求数量的最大值
+func maxInWindow(accepted []int64, window int64) int {
+	res, left := 0, 0
+	for right, now := range accepted {
+		for accepted[left] <= now-window {
+			left++
+		}
+		res = max(res, right-left+1)
+	}
+	return res
+}
+
+func TestRedisSlidingWindowCounter_Accuracy(t *testing.T) {
+	client := newRedisClient(t)
+	const (
+		window = time.Second
+		rate   = 100
+	)
+	// 起始时间对齐到固定窗口的中间，模拟的时间不依赖当前时间
+	base := time.Now().Truncate(window).UnixMilli() + window.Milliseconds()/2
+	testCases := []struct {
+		name     string
+		requests []int64
+		// 任意窗口内放行的请求数量，最多超过阈值的比例
+		overshoot float64
+		// 放行的请求总数，和精确值的最大误差
+		tolerance float64
+	}{
+		{
+			// 每12ms一个请求，持续5个窗口，流量低于阈值，估算值和精确值一致
+			name: "低于阈值",
+			requests: func() []int64 {
+				var res []int64
+				for now := base; now < base+5*window.Milliseconds(); now += 12 {
+					res = append(res, now)
+				}
+				return res
+			}(),
+		},
+		{
+			// 每3ms一个请求，持续5个窗口，流量是阈值的3倍
+			// 放行的请求集中在每个窗口的前面，不满足均匀分布的假设，滑动窗口内最多放行约1.5倍的阈值
+			name: "持续超过阈值",
+			requests: func() []int64 {
+				var res []int64
+				for now := base; now < base+5*window.Milliseconds(); now += 3 {
+					res = append(res, now)
+				}
+				return res
+			}(),
+			overshoot: 0.5,
+			tolerance: 0.15,
+		},
+		{
+			// 每个窗口的前100ms集中发送200个请求，其余时间没有请求
+			// 上一个窗口的计数被高估，放行的请求比精确值少
+			name: "突发流量",
+			requests: func() []int64 {
+				var res []int64
+				for w := int64(0); w < 5; w++ {
+					start := base + w*window.Milliseconds()
+					for i := int64(0); i < 200; i++ {
+						res = append(res, start+i/2)
+					}
+				}
+				return res
+			}(),
+			tolerance: 0.3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exact := simulate(t, client, exactRunner(client, window, rate), tc.requests)
+			counter := simulate(t, client, counterRunner(client, window, rate), tc.requests)
+
+			// 精确的滑动窗口，任意窗口内放行的请求都不超过阈值
+			if got := maxInWindow(exact, window.Milliseconds()); got > rate {
+				t.Fatalf("精确的滑动窗口超过了阈值，got=%d", got)
+			}
+			// 滑动窗口计数器，任意窗口内放行的请求不超过阈值*(1+overshoot)
+			got := maxInWindow(counter, window.Milliseconds())
+			if float64(got) > rate*(1+tc.overshoot) {
+				t.Fatalf("滑动窗口计数器的误差过大，max=%d，overshoot=%.2f", got, tc.overshoot)
+			}
+			// 放行的总数和精确值的误差
+			diff := float64(len(counter)-len(exact)) / float64(len(exact))
+			if diff > tc.tolerance || diff < -tc.tolerance {
+				t.Fatalf("放行的请求总数误差过大，exact=%d，counter=%d", len(exact), len(counter))
+			}
+			t.Logf("exact=%d，counter=%d，counter max in window=%d", len(exact), len(counter), got)
+		})
+	}
+}
+
+// TestRedisSlidingWindowLimiter_SameMillisecond 同一毫秒内的多个请求，都会被记录
+func TestRedisSlidingWindowLimiter_SameMillisecond(t *testing.T) {
+	client := newRedisClient(t)
+	now := time.Now().UnixMilli()
+	requests := make([]int64, 10)
+	for i := range requests {
+		requests[i] = now
+	}
+	for name, run := range map[string]slidingWindowRunner{
+		"精确":  exactRunner(client, time.Second, 5),
+		"计数器": counterRunner(client, time.Second, 5),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := len(simulate(t, client, run, requests)); got != 5 {
+				t.Fatalf("同一毫秒内应该放行5个请求，got=%d", got)
+			}
+		})
+	}
+}
+
+func TestRedisSlidingWindowCounterLimiter(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	key := "limitx:test:" + uuid.NewString()
+	l := NewRedisSlidingWindowCounterLimiter(client, time.Minute, 3, WithKeyPrefix("limiter"))
+	t.Cleanup(func() {
+		client.Del(ctx, "limiter{"+key+"}")
+	})
+	for i := 0; i < 3; i++ {
+		decision, err := l.Allow(ctx, key)
+		if err != nil || !decision.Allowed {
+			t.Fatalf("第%d个请求应该放行，decision=%+v，err=%v", i+1, decision, err)
+		}
+		if decision.Remaining != 2-i {
+			t.Fatalf("剩余配额错误，want=%d，got=%d", 2-i, decision.Remaining)
+		}
+	}
+	decision, err := l.Allow(ctx, key)
+	if err != nil || decision.Allowed || decision.RetryAfter <= 0 {
+		t.Fatalf("超出阈值应该限流，decision=%+v，err=%v", decision, err)
+	}
+}
+
+// TestRedisSlidingWindowCounter_ZeroRate 阈值为0时拒绝所有请求，返回合法的重试时间
+func TestRedisSlidingWindowCounter_ZeroRate(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	key := "limitx:test:" + uuid.NewString()
+	res, err := slidingWindowCounterScript.Run(ctx, client, []string{key},
+		time.Second.Milliseconds(), 0, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0] != 0 || res[3] != time.Second.Milliseconds() {
+		t.Fatalf("阈值为0应该限流，并且等待一个窗口，res=%v", res)
+	}
+	for _, rate := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("阈值不合法，应该panic，rate=%d", rate)
+				}
+			}()
+			NewRedisSlidingWindowCounterLimiter(client, time.Second, rate)
+		}()
+	}
+}
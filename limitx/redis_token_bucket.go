@@ -0,0 +1,104 @@
+package limitx
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed lua/token_bucket.lua
+var luaTokenBucket string
+
+var tokenBucketScript = redis.NewScript(luaTokenBucket)
+
+// RedisTokenBucketLimiter redis令牌桶限流
+//
+//	桶内最多存放capacity个令牌，每秒生成rate个令牌，每个请求消耗一个令牌
+//	桶装满时允许capacity个请求同时通过（突发流量），之后按照rate的速率放行
+//	每个key只保存两个字段（剩余令牌数和上一次补充的时间），内存占用和请求速率无关
+type RedisTokenBucketLimiter struct {
+	cmd      redis.Cmdable // redis客户端
+	capacity int           // 桶的容量，允许的最大突发请求数
+	rate     float64       // 令牌的生成速率，每秒生成的令牌数
+	// 比如capacity=200，rate=100，表示平均每秒允许100个请求，最多允许200个请求的突发
+	opts redisOptions
+}
+
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, capacity int,
+	rate float64, opts ...RedisOption) *RedisTokenBucketLimiter {
+	if capacity <= 0 || rate <= 0 {
+		panic("limitx: 令牌桶的容量和令牌的生成速率必须大于0")
+	}
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		capacity: capacity,
+		rate:     rate,
+		opts:     newRedisOptions(opts),
+	}
+}
+
+func (r *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	decision, err := r.Allow(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return !decision.Allowed, nil
+}
+
+// Allow 判断是否放行key的本次请求，并返回剩余令牌数和重试时间
+func (r *RedisTokenBucketLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	now := time.Now()
+	res, err := r.eval(ctx, key, now, 1, 0)
+	if err != nil {
+		return Decision{}, err
+	}
+	return newDecision(res, r.capacity, now), nil
+}
+
+// Reserve 预约一个令牌，令牌不足时预支未来生成的令牌，预约成功后需要等待Delay再执行
+//
+//	需要等待的时间超过ctx的截止时间时，不会预支令牌，返回OK=false
+func (r *RedisTokenBucketLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	res, err := r.eval(ctx, key, time.Now(), 1, maxWait(ctx))
+	if err != nil {
+		return Reservation{}, err
+	}
+	return Reservation{
+		OK:    res[0] == 1,
+		Delay: time.Duration(res[3]) * time.Millisecond,
+		cancel: func(ctx context.Context) error {
+			// 消耗-1个令牌，即归还一个令牌，最多补满
+			_, err := r.eval(ctx, key, time.Now(), -1, -1)
+			return err
+		},
+	}, nil
+}
+
+// Wait 阻塞直到获得令牌，或者ctx结束
+func (r *RedisTokenBucketLimiter) Wait(ctx context.Context, key string) error {
+	return wait(ctx, func(ctx context.Context) (Reservation, error) {
+		return r.Reserve(ctx, key)
+	})
+}
+
+// eval 执行lua脚本
+//
+//	requested 本次请求消耗的令牌数，负数表示归还令牌
+//	maxDelay 最多允许等待的时间，0表示不等待，-1表示不限制
+func (r *RedisTokenBucketLimiter) eval(ctx context.Context, key string, now time.Time,
+	requested int, maxDelay time.Duration) ([]int64, error) {
+	waitMs := int64(-1)
+	if maxDelay >= 0 {
+		waitMs = maxDelay.Milliseconds()
+	}
+	return tokenBucketScript.Run(ctx, // 上下文，用于控制请求的生命周期
+		r.cmd,                     // redis客户端
+		[]string{r.opts.key(key)}, // KEY数组
+		r.capacity,                // ARGV数组，第一个元素，桶的容量
+		r.rate/1000,               // ARGV数组，第二个元素，每毫秒生成的令牌数
+		now.UnixMilli(),           // ARGV数组，第三个元素，当前时间
+		requested,                 // ARGV数组，第四个元素，本次请求消耗的令牌数
+		waitMs).                   // ARGV数组，第五个元素，最多允许等待的毫秒数
+		Int64Slice()
+}
@@ -0,0 +1,178 @@
+package limitx
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// tokenBucketRunner 使用模拟的时间执行令牌桶脚本，返回{是否放行, 剩余令牌数, 桶被补满的毫秒数, 需要等待的毫秒数}
+type tokenBucketRunner func(now int64, maxDelay time.Duration) []int64
+
+func newTokenBucketRunner(t *testing.T, capacity int, rate float64) tokenBucketRunner {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	l := NewRedisTokenBucketLimiter(client, capacity, rate)
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	return func(now int64, maxDelay time.Duration) []int64 {
+		res, err := l.eval(ctx, key, time.UnixMilli(now), 1, maxDelay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+}
+
+// TestRedisTokenBucketLimiter_Burst 桶装满时允许capacity个请求同时通过，之后限流
+func TestRedisTokenBucketLimiter_Burst(t *testing.T) {
+	run := newTokenBucketRunner(t, 5, 10)
+	now := time.Now().UnixMilli()
+	for i := 0; i < 5; i++ {
+		res := run(now, 0)
+		if res[0] != 1 || res[1] != int64(4-i) {
+			t.Fatalf("第%d个请求应该放行，剩余%d个令牌，res=%v", i+1, 4-i, res)
+		}
+	}
+	res := run(now, 0)
+	if res[0] != 0 || res[3] != 100 {
+		t.Fatalf("令牌耗尽应该限流，并且100ms后生成下一个令牌，res=%v", res)
+	}
+}
+
+// TestRedisTokenBucketLimiter_Refill 按照流逝的时间补充令牌，最多补满capacity个
+func TestRedisTokenBucketLimiter_Refill(t *testing.T) {
+	testCases := []struct {
+		name    string
+		elapsed int64 // 令牌耗尽之后经过的毫秒数
+		allowed int   // 应该放行的请求数量
+	}{
+		{name: "补充部分令牌", elapsed: 250, allowed: 2},
+		{name: "补满令牌", elapsed: 500, allowed: 5},
+		{name: "超过容量的令牌被丢弃", elapsed: 10_000, allowed: 5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run := newTokenBucketRunner(t, 5, 10)
+			now := time.Now().UnixMilli()
+			for i := 0; i < 5; i++ {
+				run(now, 0)
+			}
+			now += tc.elapsed
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				if run(now, 0)[0] == 1 {
+					allowed++
+				}
+			}
+			if allowed != tc.allowed {
+				t.Fatalf("want=%d，got=%d", tc.allowed, allowed)
+			}
+		})
+	}
+}
+
+// TestRedisTokenBucketLimiter_Reserve 预约时令牌可以扣成负数，超过最大等待时间时不占用令牌
+func TestRedisTokenBucketLimiter_Reserve(t *testing.T) {
+	run := newTokenBucketRunner(t, 1, 10)
+	now := time.Now().UnixMilli()
+	if res := run(now, -1); res[0] != 1 || res[3] != 0 {
+		t.Fatalf("桶内有令牌，应该立即放行，res=%v", res)
+	}
+	// 令牌耗尽，依次预支未来生成的令牌
+	for i := int64(1); i <= 3; i++ {
+		res := run(now, -1)
+		if res[0] != 1 || res[3] != 100*i {
+			t.Fatalf("第%d次预约应该等待%dms，res=%v", i, 100*i, res)
+		}
+	}
+	// 需要等待400ms，超过了最大等待时间，不会扣减令牌
+	if res := run(now, 200*time.Millisecond); res[0] != 0 || res[3] != 400 {
+		t.Fatalf("超过最大等待时间应该预约失败，res=%v", res)
+	}
+	if res := run(now, -1); res[0] != 1 || res[3] != 400 {
+		t.Fatalf("预约失败不应该占用令牌，res=%v", res)
+	}
+	// 预支的令牌还清之后，恢复正常放行
+	if res := run(now+500, 0); res[0] != 1 {
+		t.Fatalf("预支的令牌已经还清，应该放行，res=%v", res)
+	}
+}
+
+// TestNewRedisTokenBucketLimiter_Invalid 容量或者速率不合法时，创建限流器直接panic，而不是在脚本中除以0
+func TestNewRedisTokenBucketLimiter_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		capacity int
+		rate     float64
+	}{
+		{name: "速率为0", capacity: 5, rate: 0},
+		{name: "速率为负数", capacity: 5, rate: -1},
+		{name: "容量为0", capacity: 0, rate: 10},
+		{name: "容量为负数", capacity: -1, rate: 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("参数不合法，应该panic，capacity=%d，rate=%v", tc.capacity, tc.rate)
+				}
+			}()
+			NewRedisTokenBucketLimiter(nil, tc.capacity, tc.rate)
+		})
+	}
+}
+
+// TestRedisTokenBucketLimiter_Cancel 取消预约时归还预支的令牌，最多补满
+func TestRedisTokenBucketLimiter_Cancel(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	l := NewRedisTokenBucketLimiter(client, 1, 10)
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	now := time.Now()
+	eval := func(requested int) []int64 {
+		res, err := l.eval(ctx, key, now, requested, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	eval(1)
+	if res := eval(1); res[3] != 100 {
+		t.Fatalf("预支第1个令牌应该等待100ms，res=%v", res)
+	}
+	// 归还预支的令牌，下一次预约仍然等待100ms，而不是200ms
+	eval(-1)
+	if res := eval(1); res[3] != 100 {
+		t.Fatalf("归还之后应该等待100ms，res=%v", res)
+	}
+	// 归还的令牌最多补满
+	eval(-1)
+	eval(-1)
+	eval(-1)
+	if res := eval(1); res[0] != 1 || res[1] != 0 || res[3] != 0 {
+		t.Fatalf("桶的容量是1，res=%v", res)
+	}
+
+	// Wait的过程中ctx被取消，归还预支的令牌
+	other := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, other)
+	})
+	l.Limit(ctx, other)
+	cancelCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := l.Wait(cancelCtx, other); err != context.Canceled {
+		t.Fatalf("want=%v，got=%v", context.Canceled, err)
+	}
+	r, err := l.Reserve(ctx, other)
+	if err != nil || !r.OK || r.Delay > 100*time.Millisecond {
+		t.Fatalf("放弃执行之后应该归还预支的令牌，got=%+v，err=%v", r, err)
+	}
+}
@@ -0,0 +1,184 @@
+package limitx
+
+import (
+	"GoToolkit/loggerx"
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sync"
+	"time"
+)
+
+// LimitConfig 一条限流规则的配置，interval内允许rate个请求
+//
+//	yaml: {interval: 10s, rate: 100}
+//	json: {"interval": "10s", "rate": 100}
+type LimitConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Rate     int           `yaml:"rate"`
+}
+
+// ConfigSource 限流配置的来源
+type ConfigSource interface {
+	// Load 加载所有规则的配置，map的key是规则名称
+	Load(ctx context.Context) (map[string]LimitConfig, error)
+}
+
+// FileSource 从YAML/JSON文件加载限流配置，文件的格式：
+//
+//	login:
+//	  interval: 10s
+//	  rate: 100
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{
+		path: path,
+	}
+}
+
+func (f *FileSource) Load(ctx context.Context) (map[string]LimitConfig, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	// JSON是YAML的子集，使用YAML解析器可以同时解析两种格式
+	configs := make(map[string]LimitConfig)
+	if err = yaml.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("解析限流配置文件失败，%w", err)
+	}
+	return configs, nil
+}
+
+// RedisHashSource 从redis的hash加载限流配置，field是规则名称，value是JSON格式的配置，比如：
+//
+//	HSET limiter:config login '{"interval": "10s", "rate": 100}'
+type RedisHashSource struct {
+	cmd redis.Cmdable
+	key string
+}
+
+func NewRedisHashSource(cmd redis.Cmdable, key string) *RedisHashSource {
+	return &RedisHashSource{
+		cmd: cmd,
+		key: key,
+	}
+}
+
+func (r *RedisHashSource) Load(ctx context.Context) (map[string]LimitConfig, error) {
+	fields, err := r.cmd.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string]LimitConfig, len(fields))
+	for name, val := range fields {
+		var cfg LimitConfig
+		if err = yaml.Unmarshal([]byte(val), &cfg); err != nil {
+			return nil, fmt.Errorf("解析限流配置失败，name：%s，%w", name, err)
+		}
+		configs[name] = cfg
+	}
+	return configs, nil
+}
+
+// Registry 限流器注册中心，按照规则名称管理RedisSlidingWindowLimiter，支持配置热更新
+//
+//	配置变更时，直接修改正在运行的限流器的参数（Update），
+//	窗口内已经记录的请求保存在redis中，不会因为配置变更而丢失
+type Registry struct {
+	cmd    redis.Cmdable
+	source ConfigSource
+	logger loggerx.Logger
+	opts   []RedisOption // 创建限流器时使用的配置
+
+	mu       sync.RWMutex
+	limiters map[string]*RedisSlidingWindowLimiter
+	configs  map[string]LimitConfig // 当前生效的配置
+}
+
+func NewRegistry(cmd redis.Cmdable, source ConfigSource, logger loggerx.Logger,
+	opts ...RedisOption) *Registry {
+	return &Registry{
+		cmd:      cmd,
+		source:   source,
+		logger:   logger,
+		opts:     opts,
+		limiters: make(map[string]*RedisSlidingWindowLimiter),
+		configs:  make(map[string]LimitConfig),
+	}
+}
+
+// Limiter 获取规则对应的限流器，规则不存在时返回false
+func (r *Registry) Limiter(name string) (*RedisSlidingWindowLimiter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	l, ok := r.limiters[name]
+	return l, ok
+}
+
+// Load 加载一次配置，创建新的限流器，更新已有限流器的参数
+func (r *Registry) Load(ctx context.Context) error {
+	configs, err := r.source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	// 先校验所有配置，避免只更新了一部分规则
+	for name, cfg := range configs {
+		if cfg.Interval <= 0 || cfg.Rate <= 0 {
+			return fmt.Errorf("限流配置不合法，name：%s，interval：%s，rate：%d",
+				name, cfg.Interval, cfg.Rate)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, cfg := range configs {
+		old, ok := r.configs[name]
+		if ok && old == cfg {
+			continue
+		}
+		r.configs[name] = cfg
+		if l, exists := r.limiters[name]; exists {
+			l.Update(cfg.Interval, cfg.Rate)
+		} else {
+			r.limiters[name] = NewRedisSlidingWindowLimiter(r.cmd, cfg.Interval, cfg.Rate, r.opts...)
+		}
+		r.logger.Info("限流配置已更新",
+			loggerx.String("name", name),
+			loggerx.String("oldInterval", old.Interval.String()),
+			loggerx.Int("oldRate", old.Rate),
+			loggerx.String("interval", cfg.Interval.String()),
+			loggerx.Int("rate", cfg.Rate))
+	}
+	// 配置中删除的规则，限流器继续使用最后一次的配置，避免正在使用的限流器失效
+	for name := range r.configs {
+		if _, ok := configs[name]; !ok {
+			delete(r.configs, name)
+			r.logger.Warn("限流配置被删除，继续使用最后一次的配置",
+				loggerx.String("name", name))
+		}
+	}
+	return nil
+}
+
+// Watch 每隔interval重新加载一次配置，直到ctx被取消，一般在单独的协程中调用
+//
+//	go registry.Watch(ctx, 10*time.Second)
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil {
+				// 加载失败，继续使用当前的配置
+				r.logger.Error("加载限流配置失败", loggerx.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package limitx
+
+import (
+	"GoToolkit/loggerx"
+	"context"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...loggerx.Field) {}
+func (nopLogger) Info(string, ...loggerx.Field)  {}
+func (nopLogger) Warn(string, ...loggerx.Field)  {}
+func (nopLogger) Error(string, ...loggerx.Field) {}
+
+// memorySource 内存中的限流配置，测试时修改配置模拟热更新
+type memorySource struct {
+	mu      sync.Mutex
+	configs map[string]LimitConfig
+}
+
+func (m *memorySource) Load(ctx context.Context) (map[string]LimitConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make(map[string]LimitConfig, len(m.configs))
+	for name, cfg := range m.configs {
+		res[name] = cfg
+	}
+	return res, nil
+}
+
+func (m *memorySource) set(name string, cfg LimitConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configs[name] = cfg
+}
+
+func TestFileSource(t *testing.T) {
+	want := map[string]LimitConfig{
+		"login": {Interval: 10 * time.Second, Rate: 100},
+		"sms":   {Interval: time.Minute, Rate: 1},
+	}
+	testCases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name: "yaml",
+			content: `
+login:
+  interval: 10s
+  rate: 100
+sms:
+  interval: 1m
+  rate: 1
+`,
+		},
+		{
+			name:    "json",
+			content: `{"login": {"interval": "10s", "rate": 100}, "sms": {"interval": "1m", "rate": 1}}`,
+		},
+		{name: "格式错误", content: "login: [", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "limiter.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			configs, err := NewFileSource(path).Load(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("配置格式错误，应该返回error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(configs) != len(want) {
+				t.Fatalf("want=%v，got=%v", want, configs)
+			}
+			for name, cfg := range want {
+				if configs[name] != cfg {
+					t.Fatalf("name=%s，want=%+v，got=%+v", name, cfg, configs[name])
+				}
+			}
+		})
+	}
+}
+
+func TestRedisHashSource(t *testing.T) {
+	client := newRedisClient(t)
+	ctx := context.Background()
+	key := "limitx:test:" + uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(ctx, key)
+	})
+	client.HSet(ctx, key, "login", `{"interval": "10s", "rate": 100}`)
+	configs, err := NewRedisHashSource(client, key).Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (LimitConfig{Interval: 10 * time.Second, Rate: 100}); configs["login"] != want {
+		t.Fatalf("want=%+v，got=%+v", want, configs["login"])
+	}
+	client.HSet(ctx, key, "sms", `{"interval": `)
+	if _, err = NewRedisHashSource(client, key).Load(ctx); err == nil {
+		t.Fatal("配置格式错误，应该返回error")
+	}
+}
+
+// TestRegistry_Watch 配置变更后，正在使用的限流器直接使用新的参数
+func TestRegistry_Watch(t *testing.T) {
+	client := newRedisClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := &memorySource{configs: map[string]LimitConfig{
+		"login": {Interval: time.Minute, Rate: 1},
+	}}
+	registry := NewRegistry(client, source, nopLogger{}, WithKeyPrefix("limitx:test"))
+	if err := registry.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l, ok := registry.Limiter("login")
+	if !ok {
+		t.Fatal("规则login应该存在")
+	}
+	key := uuid.NewString()
+	t.Cleanup(func() {
+		client.Del(context.Background(), "limitx:test{"+key+"}")
+	})
+	if limit, err := l.Limit(ctx, key); err != nil || limit {
+		t.Fatalf("第1个请求应该放行，limit=%v，err=%v", limit, err)
+	}
+	if limit, err := l.Limit(ctx, key); err != nil || !limit {
+		t.Fatalf("超出阈值应该限流，limit=%v，err=%v", limit, err)
+	}
+
+	// 不合法的配置整体被拒绝，继续使用当前的配置
+	source.set("sms", LimitConfig{Interval: time.Minute})
+	if err := registry.Load(ctx); err == nil {
+		t.Fatal("配置不合法，应该返回error")
+	}
+	if _, ok = registry.Limiter("sms"); ok {
+		t.Fatal("不合法的配置不应该创建限流器")
+	}
+
+	source.set("sms", LimitConfig{Interval: time.Minute, Rate: 1})
+	source.set("login", LimitConfig{Interval: time.Minute, Rate: 3})
+	go registry.Watch(ctx, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := registry.Limiter("login"); got != l {
+		t.Fatal("配置变更时，应该更新已有的限流器，而不是创建新的限流器")
+	}
+	if _, ok = registry.Limiter("sms"); !ok {
+		t.Fatal("新增的规则应该创建限流器")
+	}
+	// 窗口内已经记录了1个请求，阈值调整为3之后，还可以放行2个请求
+	for i := 0; i < 2; i++ {
+		if limit, err := l.Limit(ctx, key); err != nil || limit {
+			t.Fatalf("阈值调整后应该放行，limit=%v，err=%v", limit, err)
+		}
+	}
+	if limit, err := l.Limit(ctx, key); err != nil || !limit {
+		t.Fatalf("超出新的阈值应该限流，limit=%v，err=%v", limit, err)
+	}
+}
@@ -1,6 +1,9 @@
 package limitx
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Limiter 限流器
 type Limiter interface {
@@ -8,3 +11,20 @@ type Limiter interface {
 	// bool 返回true表示限流，返回false表示不限流
 	Limit(ctx context.Context, key string) (bool, error)
 }
+
+// Decision 限流决策，除了是否放行，还包含剩余配额和重试时间，
+// 可以用来设置HTTP的X-RateLimit-*、Retry-After响应头，或者gRPC的RetryInfo
+type Decision struct {
+	Allowed    bool          // true表示放行，false表示限流
+	Limit      int           // 阈值，窗口内允许的请求数量（令牌桶为桶的容量）
+	Remaining  int           // 剩余配额
+	ResetAt    time.Time     // 配额完全恢复的时间
+	RetryAfter time.Duration // 被限流时，距离下一个配额可用还需要等待的时间；放行时为0
+}
+
+// DecisionLimiter 可以返回限流决策的限流器
+type DecisionLimiter interface {
+	Limiter
+	// Allow 判断是否放行key的本次请求，并返回限流决策
+	Allow(ctx context.Context, key string) (Decision, error)
+}
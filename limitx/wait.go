@@ -0,0 +1,107 @@
+package limitx
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWaitExceedsDeadline 需要等待的时间超过了ctx的截止时间，不会占用配额
+var ErrWaitExceedsDeadline = errors.New("limitx: 需要等待的时间超过了ctx的截止时间")
+
+// Reservation 预约的结果
+type Reservation struct {
+	OK    bool          // true表示预约成功，配额已经被占用，等待Delay之后执行；false表示没有配额，Delay之后重试
+	Delay time.Duration // 需要等待的时间
+	// cancel 归还预支的令牌，只有令牌桶预约成功时不为nil
+	cancel func(ctx context.Context) error
+}
+
+// Cancel 取消预约，归还预支的令牌，类似golang.org/x/time/rate的Reservation.Cancel
+//
+//	预约成功之后，在等待Delay的过程中放弃执行（比如ctx被取消）时调用，否则预支的令牌不会被归还，
+//	后续的请求需要多等待一个令牌的时间；已经执行之后不能再调用，只能调用一次
+//	预约失败，或者限流器无法预支配额（滑动窗口、固定窗口）时，不做任何事情
+func (r Reservation) Cancel(ctx context.Context) error {
+	if !r.OK || r.cancel == nil {
+		return nil
+	}
+	return r.cancel(ctx)
+}
+
+// BlockingLimiter 阻塞式限流器，超出阈值时等待配额，而不是直接拒绝
+//
+//	适合后台任务（延迟队列、kafka消费者）调用有频率限制的第三方接口（比如短信服务商），宁可变慢也不丢弃任务：
+//
+//	if err := limiter.Wait(ctx, "sms:provider"); err != nil {
+//		return err
+//	}
+//	return provider.Send(ctx, ...)
+type BlockingLimiter interface {
+	Limiter
+	// Wait 阻塞直到获得配额，或者ctx结束（返回ctx.Err()或ErrWaitExceedsDeadline）
+	Wait(ctx context.Context, key string) error
+	// Reserve 预约一个配额，不会阻塞
+	// 令牌桶会预支未来生成的令牌，预约成功后必须等待Delay再执行，放弃执行时调用Reservation.Cancel归还；
+	// 滑动窗口、固定窗口无法预支，没有配额时返回OK=false，Delay是建议的重试时间
+	Reserve(ctx context.Context, key string) (Reservation, error)
+}
+
+// maxWait ctx剩余的时间，ctx没有截止时间时返回-1，表示不限制
+func maxWait(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return -1
+	}
+	return max(0, time.Until(deadline))
+}
+
+// reserveByDecision 根据限流决策预约，用于无法预支配额的限流器（滑动窗口、固定窗口）
+func reserveByDecision(decision Decision) Reservation {
+	if decision.Allowed {
+		return Reservation{OK: true}
+	}
+	return Reservation{Delay: decision.RetryAfter}
+}
+
+// wait 循环预约，直到预约成功并等待Delay，或者ctx结束
+func wait(ctx context.Context, reserve func(ctx context.Context) (Reservation, error)) error {
+	for {
+		r, err := reserve(ctx)
+		if err != nil {
+			return err
+		}
+		if r.OK {
+			if err = sleep(ctx, r.Delay); err != nil {
+				// 放弃执行，归还预支的令牌；ctx已经结束，使用新的ctx，归还失败时令牌会随着时间自然补充
+				cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+				_ = r.Cancel(cancelCtx)
+				cancel()
+			}
+			return err
+		}
+		// 没有配额，等到配额可用之后重试
+		limit := maxWait(ctx)
+		if limit >= 0 && r.Delay > limit {
+			return ErrWaitExceedsDeadline
+		}
+		if err = sleep(ctx, max(r.Delay, time.Millisecond)); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep 等待d，或者ctx结束
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
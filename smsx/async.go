@@ -0,0 +1,60 @@
+package smsx
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// Producer kafka生产者，kafkax.KafkaProducer实现了这个接口
+type Producer interface {
+	Send(message kafka.Message)
+}
+
+// AsyncService 把短信写入kafka，由消费者调用真正的短信服务发送，削峰并且隔离短信服务商的故障
+//
+//	生产者：
+//
+//	svc := smsx.NewAsyncService(kafkax.NewKafkaProducer(addr, "sms"))
+//
+//	消费者：
+//
+//	kafkax.NewKafkaConsumer[smsx.SMS](addr, "sms-consumer", "sms", time.Second, smsx.NewAsyncHandler(real, 5*time.Second))
+type AsyncService struct {
+	producer Producer
+}
+
+func NewAsyncService(producer Producer) *AsyncService {
+	return &AsyncService{
+		producer: producer,
+	}
+}
+
+// Send 把短信写入kafka，写入是异步的，返回nil不代表短信已经发送
+func (a *AsyncService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	value, err := json.Marshal(SMS{
+		TplId:   tplId,
+		Args:    args,
+		Numbers: numbers,
+	})
+	if err != nil {
+		return err
+	}
+	msg := kafka.Message{Value: value}
+	if len(numbers) > 0 {
+		// 同一个手机号的短信写入同一个分区，保证顺序
+		msg.Key = []byte(numbers[0])
+	}
+	a.producer.Send(msg)
+	return nil
+}
+
+// NewAsyncHandler 创建kafka消费者的处理函数，调用svc发送短信，返回error时消息不会被提交
+func NewAsyncHandler(svc Service, timeout time.Duration) func(val SMS) error {
+	return func(val SMS) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return svc.Send(ctx, val.TplId, val.Args, val.Numbers...)
+	}
+}
@@ -0,0 +1,43 @@
+package smsx
+
+import (
+	"GoToolkit/loggerx"
+	"context"
+	"fmt"
+)
+
+// FailoverService 按照优先级依次尝试多个短信服务商，有一个发送成功就返回
+type FailoverService struct {
+	svcs   []Service // 按照优先级排序，第一个的优先级最高
+	logger loggerx.Logger
+}
+
+// NewFailoverService 创建故障转移的短信服务，svcs按照优先级排序，不能为空
+func NewFailoverService(logger loggerx.Logger, svcs ...Service) *FailoverService {
+	if len(svcs) == 0 {
+		// 没有服务商时Send会返回被ErrAllFailed包装的nil，调用方无法区分原因
+		panic("smsx: 至少需要一个短信服务商")
+	}
+	return &FailoverService{
+		svcs:   svcs,
+		logger: logger,
+	}
+}
+
+func (f *FailoverService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	var err error
+	for i, svc := range f.svcs {
+		err = svc.Send(ctx, tplId, args, numbers...)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			// 超时或者被取消，不再尝试其他服务商
+			return ctx.Err()
+		}
+		f.logger.Warn("短信服务商发送失败，尝试下一个服务商", loggerx.Error(err),
+			loggerx.Int("index", i),
+			loggerx.String("tplId", tplId))
+	}
+	return fmt.Errorf("%w，%w", ErrAllFailed, err)
+}
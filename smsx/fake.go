@@ -0,0 +1,58 @@
+package smsx
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// FakeService 内存中的短信服务，记录发送的短信，不会真正发送，用于本地开发和单元测试
+type FakeService struct {
+	mu   sync.Mutex
+	sent []SMS
+	err  error // 不为nil时，Send返回这个错误
+}
+
+func NewFakeService() *FakeService {
+	return &FakeService{}
+}
+
+func (f *FakeService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, SMS{
+		TplId:   tplId,
+		Args:    slices.Clone(args),
+		Numbers: slices.Clone(numbers),
+	})
+	return nil
+}
+
+// SetError 设置Send返回的错误，用于模拟服务商故障，nil表示恢复正常
+func (f *FakeService) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Sent 返回已经发送的短信
+func (f *FakeService) Sent() []SMS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.sent)
+}
+
+// Last 返回最后一条发送给number的短信，没有时返回false
+func (f *FakeService) Last(number string) (SMS, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.sent) - 1; i >= 0; i-- {
+		if slices.Contains(f.sent[i].Numbers, number) {
+			return f.sent[i], true
+		}
+	}
+	return SMS{}, false
+}
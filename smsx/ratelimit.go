@@ -0,0 +1,36 @@
+package smsx
+
+import (
+	"GoToolkit/limitx"
+	"context"
+	"fmt"
+)
+
+// RateLimitService 限制调用短信服务商的频率，避免超过服务商的频率限制
+//
+//	触发限流时返回ErrLimited，和FailoverService一起使用时，会切换到下一个服务商
+type RateLimitService struct {
+	svc     Service
+	limiter limitx.Limiter
+	key     string // 限流对象，一般是服务商的名称，比如sms:aliyun
+}
+
+func NewRateLimitService(svc Service, limiter limitx.Limiter, key string) *RateLimitService {
+	return &RateLimitService{
+		svc:     svc,
+		limiter: limiter,
+		key:     key,
+	}
+}
+
+func (r *RateLimitService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	limited, err := r.limiter.Limit(ctx, r.key)
+	if err != nil {
+		// 限流器出错，保守策略，不发送
+		return fmt.Errorf("短信服务限流器执行失败，%w", err)
+	}
+	if limited {
+		return ErrLimited
+	}
+	return r.svc.Send(ctx, tplId, args, numbers...)
+}
@@ -0,0 +1,42 @@
+package smsx
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryService 发送失败时重试，每次重试的间隔按照重试次数线性增加
+//
+//	限流（ErrLimited）和ctx结束时不重试
+type RetryService struct {
+	svc      Service
+	maxRetry int           // 最多重试的次数
+	interval time.Duration // 第一次重试的间隔
+}
+
+func NewRetryService(svc Service, maxRetry int, interval time.Duration) *RetryService {
+	return &RetryService{
+		svc:      svc,
+		maxRetry: maxRetry,
+		interval: interval,
+	}
+}
+
+func (r *RetryService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	err := r.svc.Send(ctx, tplId, args, numbers...)
+	for i := 0; i < r.maxRetry && err != nil; i++ {
+		if errors.Is(err, ErrLimited) || ctx.Err() != nil {
+			return err
+		}
+		timer := time.NewTimer(r.interval * time.Duration(i+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		err = r.svc.Send(ctx, tplId, args, numbers...)
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package smsx
+
+import (
+	"GoToolkit/limitx"
+	"GoToolkit/loggerx"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// nopLogger 不输出任何日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...loggerx.Field) {}
+func (nopLogger) Info(string, ...loggerx.Field)  {}
+func (nopLogger) Warn(string, ...loggerx.Field)  {}
+func (nopLogger) Error(string, ...loggerx.Field) {}
+
+// flakyService 前failures次发送失败
+type flakyService struct {
+	*FakeService
+	failures int
+}
+
+func (f *flakyService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("服务商故障")
+	}
+	return f.FakeService.Send(ctx, tplId, args, numbers...)
+}
+
+func TestRetryService(t *testing.T) {
+	fake := &flakyService{FakeService: NewFakeService(), failures: 2}
+	svc := NewRetryService(fake, 2, time.Millisecond)
+	if err := svc.Send(context.Background(), "login", []string{"123456"}, "13800138000"); err != nil {
+		t.Fatalf("重试2次后应该发送成功，err=%v", err)
+	}
+	fake.failures = 3
+	if err := svc.Send(context.Background(), "login", []string{"123456"}, "13800138000"); err == nil {
+		t.Fatal("超过重试次数，应该发送失败")
+	}
+}
+
+func TestFailoverService(t *testing.T) {
+	primary, backup := NewFakeService(), NewFakeService()
+	// 主服务商限流，切换到备用服务商
+	limited := NewRateLimitService(primary, limitx.NewLocalFixedWindowLimiter(time.Minute, 1), "sms:primary")
+	svc := NewFailoverService(nopLogger{}, limited, backup)
+	for i := 0; i < 2; i++ {
+		if err := svc.Send(context.Background(), "login", []string{"123456"}, "13800138000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(primary.Sent()) != 1 || len(backup.Sent()) != 1 {
+		t.Fatalf("主服务商限流后应该切换到备用服务商，primary=%d，backup=%d",
+			len(primary.Sent()), len(backup.Sent()))
+	}
+	// 所有服务商都失败
+	backup.SetError(errors.New("服务商故障"))
+	err := svc.Send(context.Background(), "login", []string{"123456"}, "13800138000")
+	if !errors.Is(err, ErrAllFailed) {
+		t.Fatalf("want=%v，got=%v", ErrAllFailed, err)
+	}
+}
+
+func TestNewFailoverService_Empty(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("没有短信服务商，应该panic")
+		}
+	}()
+	NewFailoverService(nopLogger{})
+}
+
+// errLimiter 返回固定结果的限流器
+type errLimiter struct {
+	limited bool
+	err     error
+}
+
+func (e errLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	return e.limited, e.err
+}
+
+func TestRateLimitService(t *testing.T) {
+	limiterErr := errors.New("redis不可用")
+	testCases := []struct {
+		name     string
+		limiter  limitx.Limiter
+		wantErr  error
+		wantSent int
+	}{
+		{name: "没有触发限流", limiter: errLimiter{}, wantSent: 1},
+		{name: "触发限流", limiter: errLimiter{limited: true}, wantErr: ErrLimited},
+		// 限流器出错时不发送，返回限流器的错误
+		{name: "限流器执行失败", limiter: errLimiter{err: limiterErr}, wantErr: limiterErr},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := NewFakeService()
+			svc := NewRateLimitService(fake, tc.limiter, "sms:test")
+			err := svc.Send(context.Background(), "login", []string{"123456"}, "13800138000")
+			if tc.wantErr == nil && err != nil || tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want=%v，got=%v", tc.wantErr, err)
+			}
+			if len(fake.Sent()) != tc.wantSent {
+				t.Fatalf("want发送%d条，got=%d", tc.wantSent, len(fake.Sent()))
+			}
+		})
+	}
+}
+
+// chanProducer 把消息写入管道，代替kafka
+type chanProducer chan kafka.Message
+
+func (c chanProducer) Send(message kafka.Message) {
+	c <- message
+}
+
+func TestAsyncService(t *testing.T) {
+	producer := make(chanProducer, 1)
+	if err := NewAsyncService(producer).Send(context.Background(), "login",
+		[]string{"123456"}, "13800138000"); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-producer
+	var sms SMS
+	if err := json.Unmarshal(msg.Value, &sms); err != nil {
+		t.Fatal(err)
+	}
+	// 消费者调用真正的短信服务
+	fake := NewFakeService()
+	if err := NewAsyncHandler(fake, time.Second)(sms); err != nil {
+		t.Fatal(err)
+	}
+	last, ok := fake.Last("13800138000")
+	if !ok || last.TplId != "login" || last.Args[0] != "123456" {
+		t.Fatalf("消费者应该发送短信，got=%+v", last)
+	}
+}
@@ -0,0 +1,27 @@
+package smsx
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrLimited   = errors.New("smsx: 短信服务商触发限流")
+	ErrAllFailed = errors.New("smsx: 所有短信服务商都发送失败")
+)
+
+// Service 短信服务，不同的短信服务商（阿里云、腾讯云）实现这个接口
+//
+//	装饰器可以自由组合，比如：每个服务商限流+重试，再按照优先级故障转移，最后通过kafka异步发送
+type Service interface {
+	// Send 使用模板发送短信
+	// tplId 短信模板id，args 模板参数（按照模板中参数的顺序），numbers 手机号
+	Send(ctx context.Context, tplId string, args []string, numbers ...string) error
+}
+
+// SMS 一条短信，用于异步发送时序列化到kafka，以及FakeService记录发送的短信
+type SMS struct {
+	TplId   string   `json:"tplId"`
+	Args    []string `json:"args"`
+	Numbers []string `json:"numbers"`
+}